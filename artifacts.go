@@ -0,0 +1,172 @@
+package batexpe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A file produced or read by a simulation
+type ArtifactFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256,omitempty"`
+	ModTime time.Time `json:"mtime"`
+	Err     string    `json:"err,omitempty"`
+}
+
+// Files produced and read by a simulation, exported as JSON in the output
+// directory after a successful run
+type ArtifactManifest struct {
+	Outputs []ArtifactFile `json:"outputs"`
+	Inputs  []ArtifactFile `json:"inputs"`
+}
+
+func ArtifactsFilename(outputDir string) string {
+	return outputDir + "/artifacts.json"
+}
+
+// Describes one file. Unreadable files are kept with their error.
+func newArtifactFile(path string) ArtifactFile {
+	artifact := ArtifactFile{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		artifact.Err = err.Error()
+		return artifact
+	}
+	artifact.Size = info.Size()
+	artifact.ModTime = info.ModTime()
+
+	file, err := os.Open(path)
+	if err != nil {
+		artifact.Err = err.Error()
+		return artifact
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		artifact.Err = err.Error()
+		return artifact
+	}
+	artifact.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return artifact
+}
+
+// Lists the files produced by a simulation: the files of the output
+// directory (except archived runs and the manifest itself) and the files of
+// Batsim's export prefix
+func listOutputFiles(outputDir string, batargs BatsimArgs) ([]string,
+	error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+		if !seen[absPath] {
+			seen[absPath] = true
+			files = append(files, path)
+		}
+	}
+
+	manifest := filepath.Clean(ArtifactsFilename(outputDir))
+	archive := filepath.Clean(outputDir + "/archive")
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo,
+		err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && filepath.Clean(path) == archive {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && filepath.Clean(path) != manifest {
+			add(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return files, err
+	}
+
+	if batargs.ExportPrefix != "" {
+		matches, err := filepath.Glob(batargs.ExportPrefix + "*")
+		if err != nil {
+			return files, err
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err == nil && info.Mode().IsRegular() {
+				add(match)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func NewArtifactManifest(outputDir string,
+	batargs BatsimArgs) (ArtifactManifest, error) {
+	manifest := ArtifactManifest{
+		Outputs: []ArtifactFile{},
+		Inputs:  []ArtifactFile{},
+	}
+
+	files, err := listOutputFiles(outputDir, batargs)
+	if err != nil {
+		return manifest, err
+	}
+	for _, file := range files {
+		manifest.Outputs = append(manifest.Outputs, newArtifactFile(file))
+	}
+
+	var inputs []string
+	if batargs.Platform != "" {
+		inputs = append(inputs, batargs.Platform)
+	}
+	inputs = append(inputs, batargs.Workloads...)
+	inputs = append(inputs, batargs.Workflows...)
+	for _, input := range inputs {
+		manifest.Inputs = append(manifest.Inputs, newArtifactFile(input))
+	}
+
+	return manifest, nil
+}
+
+// Writes the artifact manifest of a simulation in its output directory
+func WriteArtifactManifest(outputDir string, batargs BatsimArgs) error {
+	manifest, err := NewArtifactManifest(outputDir, batargs)
+	if err == nil {
+		var byt []byte
+		byt, err = json.MarshalIndent(manifest, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(ArtifactsFilename(outputDir), byt, 0644)
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":           err,
+			"manifest file": ArtifactsFilename(outputDir),
+		}).Error("Cannot write artifact manifest")
+		return fmt.Errorf("Cannot write artifact manifest")
+	}
+
+	log.WithFields(log.Fields{
+		"manifest file": ArtifactsFilename(outputDir),
+		"outputs":       len(manifest.Outputs),
+		"inputs":        len(manifest.Inputs),
+	}).Info("Artifact manifest written")
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package batexpetest helps testing Batsim experiments (e.g. the ones of a
+// scheduler) with the go tool, without bats.
+//
+// A typical table-driven test builds one experiment per case with
+// NewExperiment, runs it with Run, then checks the outcome with the Assert*
+// functions.
+// Experiments can run on batexpe's fake Batsim (see BuildFakeBatsim) to test
+// robin itself without Batsim.
+package batexpetest
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Package of batexpe's fake Batsim
+const fakeBatsimPackage = "github.com/Lucas-Doctorate-Project/batexpe/" +
+	"cmd/fakebatsim"
+
+// Timeouts of the experiments returned by NewExperiment, in seconds
+const (
+	DefaultSimulationTimeout = 60
+	DefaultReadyTimeout      = 10
+	DefaultSuccessTimeout    = 10
+	DefaultFailureTimeout    = 5
+)
+
+// The outcome of one experiment execution
+type Result struct {
+	Experiment batexpe.Experiment
+	// Return code of the execution, as robin's
+	ReturnCode int
+	// The result robin writes in the output directory (result.json).
+	// Its State is empty if robin could not write it.
+	Run batexpe.RunResult
+	// The log entries emitted during the execution
+	Events batexpe.RobinEvents
+	// The log entries emitted during the execution, in JSON (one per line)
+	Output string
+}
+
+// Builds batexpe's fake Batsim (fakebatsim) in dir and returns its path.
+// The go tool is required. Meant to be called once per test binary
+// (e.g. in TestMain), as building takes time.
+func BuildFakeBatsim(dir string) (string, error) {
+	binary := filepath.Join(dir, "fakebatsim")
+	build := exec.Command("go", "build", "-o", binary, fakeBatsimPackage)
+	if out, err := build.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("Cannot build fakebatsim: %s\n%s", err, out)
+	}
+	return binary, nil
+}
+
+// Returns a TCP socket endpoint (as given to Batsim's -s option) whose port
+// is not in use
+func FreeSocket(t testing.TB) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return fmt.Sprintf("tcp://localhost:%d",
+		listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Creates a temporary output directory, removed at the end of the test
+func OutputDir(t testing.TB) string {
+	t.Helper()
+	return t.TempDir()
+}
+
+// Returns an experiment that executes batcmd and schedcmd (none if empty) in
+// a temporary output directory.
+// Batsim's export prefix is put in the output directory if batcmd sets none.
+func NewExperiment(t testing.TB, batcmd, schedcmd string) batexpe.Experiment {
+	t.Helper()
+	return batexpe.Experiment{
+		Batcmd:             batcmd,
+		OutputDir:          OutputDir(t),
+		Schedcmd:           schedcmd,
+		SimulationTimeout:  DefaultSimulationTimeout,
+		ReadyTimeout:       DefaultReadyTimeout,
+		SuccessTimeout:     DefaultSuccessTimeout,
+		FailureTimeout:     DefaultFailureTimeout,
+		ExportPrefixPolicy: batexpe.ExportPrefixInject,
+	}
+}
+
+// Executes an experiment in the current process, as robin would.
+// As the log entries are captured from the standard logger, experiments
+// must not be run concurrently (e.g. in parallel tests).
+func Run(t testing.TB, exp batexpe.Experiment) Result {
+	t.Helper()
+	return RunWithOptions(t, exp, batexpe.ExecuteOptions{})
+}
+
+func RunWithOptions(t testing.TB, exp batexpe.Experiment,
+	opts batexpe.ExecuteOptions) Result {
+	t.Helper()
+	rresult, events := batexpe.RunRobinInProcess(exp, opts)
+
+	result := Result{
+		Experiment: exp,
+		ReturnCode: rresult.ReturnCode,
+		Events:     events,
+		Output:     rresult.Output,
+	}
+
+	run, err := batexpe.ReadResult(exp.OutputDir)
+	if err == nil {
+		result.Run = run
+	} else {
+		t.Logf("Cannot read result of %s: %s", exp.OutputDir, err)
+	}
+	return result
+}
+
+// Checks the state of an execution (as in result.json: success, failure,
+// timeout, total-timeout, stalled, aborted, context-invalid or setup-failure)
+func AssertState(t testing.TB, result Result, state string) {
+	t.Helper()
+	if result.Run.State != state {
+		t.Errorf("Unexpected execution state: expected %s, got %s\n%s",
+			state, result.Run.State, result.Output)
+	}
+}
+
+func AssertSuccess(t testing.TB, result Result) {
+	t.Helper()
+	AssertState(t, result, "success")
+}
+
+// Checks the state of a process (Batsim or Scheduler) of an execution
+func AssertProcessState(t testing.TB, result Result, process, state string) {
+	t.Helper()
+	processResult, found := result.Run.Processes[process]
+	if !found {
+		t.Errorf("No result for process %s", process)
+	} else if processResult.State != state {
+		t.Errorf("Unexpected %s state: expected %s, got %s (err=%s)",
+			process, state, processResult.State, processResult.Err)
+	}
+}
+
+// Checks which timeout made robin kill a process (none if not killed by a
+// timeout)
+func AssertKilledBy(t testing.TB, result Result, process, timeout string) {
+	t.Helper()
+	got := result.Events.KillingTimeout(process)
+	if got == "" {
+		got = "none"
+	}
+	if got != timeout {
+		t.Errorf("Unexpected %s killing timeout: expected %s, got %s",
+			process, timeout, got)
+	}
+}
+
+// Checks that an event (one of batexpe's Event* values) has been logged
+func AssertEvent(t testing.TB, result Result, kind string) {
+	t.Helper()
+	if !hasEvent(result, kind) {
+		t.Errorf("Missing %s event", kind)
+	}
+}
+
+// Checks that an event (one of batexpe's Event* values) has not been logged
+func AssertNoEvent(t testing.TB, result Result, kind string) {
+	t.Helper()
+	if hasEvent(result, kind) {
+		t.Errorf("Unexpected %s event", kind)
+	}
+}
+
+func hasEvent(result Result, kind string) bool {
+	for _, event := range result.Events {
+		if event.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
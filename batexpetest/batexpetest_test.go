@@ -0,0 +1,104 @@
+package batexpetest
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Path of the fake Batsim built for the tests
+var fakeBatsim string
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := ioutil.TempDir("", "batexpetest")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create temporary directory:", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	fakeBatsim, err = BuildFakeBatsim(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		batsimOptions string
+		schedcmd      string
+		state         string
+		batsimState   string
+		batsimKiller  string
+	}{
+		{"batexec-success", "--batexec", "", "success", "success", "none"},
+		{"batexec-failure", "--batexec --fake-exit-code=2", "", "failure",
+			"failure", "none"},
+		{"batexec-timeout", "--batexec --fake-hang", "", "timeout", "timeout",
+			"simulation-timeout"},
+		{"sched-success", "", "true", "success", "success", "none"},
+		{"sched-failure", "--fake-duration=10", "false", "failure",
+			"failure", "failure-timeout"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp := NewExperiment(t, fmt.Sprintf("%s -s %s %s", fakeBatsim,
+				FreeSocket(t), test.batsimOptions), test.schedcmd)
+			exp.SimulationTimeout = 2
+			exp.FailureTimeout = 0
+
+			result := Run(t, exp)
+			AssertState(t, result, test.state)
+			AssertProcessState(t, result, "Batsim", test.batsimState)
+			AssertKilledBy(t, result, "Batsim", test.batsimKiller)
+			AssertEvent(t, result, batexpe.EventRobinFinished)
+			if test.state == "success" {
+				AssertSuccess(t, result)
+				AssertNoEvent(t, result, batexpe.EventProcessFailed)
+			}
+
+			if result.ReturnCode != result.Run.ReturnCode {
+				t.Errorf("Unexpected return code: %d (%d in result)",
+					result.ReturnCode, result.Run.ReturnCode)
+			}
+		})
+	}
+}
+
+func TestNewExperimentInjectsExportPrefix(t *testing.T) {
+	exp := NewExperiment(t, fakeBatsim+" --batexec -s "+FreeSocket(t), "")
+	result := Run(t, exp)
+	AssertSuccess(t, result)
+
+	if _, err := os.Stat(exp.OutputDir + "/out_jobs.csv"); err != nil {
+		t.Errorf("Batsim's export prefix is not in the output directory: %s",
+			err)
+	}
+}
+
+// The return code must not depend on the log entries that are captured
+func TestRunWithWarningLogLevel(t *testing.T) {
+	level := log.GetLevel()
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(level)
+
+	exp := NewExperiment(t, fakeBatsim+" --batexec -s "+FreeSocket(t), "")
+	result := Run(t, exp)
+	AssertNoEvent(t, result, batexpe.EventRobinFinished)
+	AssertSuccess(t, result)
+	if result.ReturnCode != 0 {
+		t.Errorf("Unexpected return code: %d", result.ReturnCode)
+	}
+}
@@ -7,12 +7,42 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 type BatsimArgs struct {
 	Socket        string
 	ExportPrefix  string
 	BatexecMode   bool
+	Platform      string
+	Workloads     []string
+	Workflows     []string
+}
+
+// Retrieves the input files (platform, workloads and workflows) given to
+// Batsim from the words of its command.
+// Environment variables are expanded as bash would do it.
+func parseBatsimInputs(batcmd string, batargs *BatsimArgs) {
+	words := strings.Fields(batcmd)
+	for i := 0; i < len(words); i++ {
+		option, value := words[i], ""
+		if equal := strings.Index(option, "="); equal != -1 &&
+			strings.HasPrefix(option, "--") {
+			option, value = option[:equal], option[equal+1:]
+		} else if i+1 < len(words) {
+			value = words[i+1]
+		}
+		value = os.ExpandEnv(strings.Trim(value, "'\""))
+
+		switch option {
+		case "-p", "--platform":
+			batargs.Platform = value
+		case "-w", "--workload":
+			batargs.Workloads = append(batargs.Workloads, value)
+		case "-W", "--workflow":
+			batargs.Workflows = append(batargs.Workflows, value)
+		}
+	}
 }
 
 func ParseBatsimCommand(batcmd string) (batargs BatsimArgs, err error) {
@@ -54,6 +84,7 @@ func ParseBatsimCommand(batcmd string) (batargs BatsimArgs, err error) {
 	batargs.Socket = jsonData["socket_endpoint"].(string)
 	batargs.ExportPrefix = jsonData["export_prefix"].(string)
 	batargs.BatexecMode = !jsonData["external_scheduler"].(bool)
+	parseBatsimInputs(batcmd, &batargs)
 
 	return batargs, nil
 }
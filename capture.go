@@ -0,0 +1,83 @@
+package batexpe
+
+import (
+	"bytes"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Logrus hook that records the log entries emitted during an in-process
+// robin execution, as robin would print them with --json-logs
+type eventCaptureHook struct {
+	mutex     sync.Mutex
+	formatter log.JSONFormatter
+	output    bytes.Buffer
+	events    RobinEvents
+}
+
+func (hook *eventCaptureHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (hook *eventCaptureHook) Fire(entry *log.Entry) error {
+	line, err := hook.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	// Fields are read back from JSON, so that events are the same as the
+	// ones parsed from robin's output (e.g. errors become strings)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return err
+	}
+
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	hook.output.Write(line)
+	hook.events = append(hook.events, NewRobinEvent(fields))
+	return nil
+}
+
+// Executes an experiment in the current process, as robin would, while
+// capturing the log entries emitted meanwhile.
+// The entries are also logged as usual by the standard logger, whose level
+// filters what is captured.
+// As the standard logger is shared, the entries logged by other goroutines
+// during the execution are captured too: in-process executions should not
+// run concurrently.
+// The result is always finished, as the execution cannot be killed: use the
+// experiment timeouts (e.g. total-timeout) to bound its duration.
+func RunRobinInProcess(exp Experiment, opts ExecuteOptions) (RobinResult,
+	RobinEvents) {
+	hook := &eventCaptureHook{}
+	logger := log.StandardLogger()
+	logger.AddHook(hook)
+	defer func() {
+		// Only remove the capture hook, other hooks may have been added
+		hooks := make(log.LevelHooks)
+		for level, levelHooks := range logger.Hooks {
+			for _, levelHook := range levelHooks {
+				if levelHook != log.Hook(hook) {
+					hooks[level] = append(hooks[level], levelHook)
+				}
+			}
+		}
+		logger.ReplaceHooks(hooks)
+	}()
+
+	start := time.Now()
+	returnCode := ExecuteOneWithOptions(exp, opts)
+
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	return RobinResult{
+		Finished:   true,
+		Succeeded:  returnCode == 0,
+		ReturnCode: returnCode,
+		Output:     hook.output.String(),
+		Duration:   time.Since(start).Seconds(),
+	}, hook.events
+}
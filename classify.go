@@ -0,0 +1,220 @@
+package batexpe
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// What is known about a failed process, as given to error classifiers
+type ProcessFailure struct {
+	Name     string
+	Err      error
+	ExitCode int            // -1 if the process has been killed by a signal
+	Signal   syscall.Signal // 0 if no signal killed the process or its command
+	LogFiles []string
+	// Content of the log files (only their beginning and end for big files)
+	Logs []string
+}
+
+// Category of an error, with the short log excerpt that motivated it
+type ErrorClassification struct {
+	Category string `json:"category"`
+	Excerpt  string `json:"excerpt,omitempty"`
+}
+
+// Classifies a process failure.
+// The boolean is false if the classifier does not recognize the failure.
+type ErrorClassifier func(failure ProcessFailure) (ErrorClassification, bool)
+
+const UnknownErrorCategory = "unknown"
+
+// Amount of log read from the beginning and from the end of each log file
+const (
+	classifierHeadBytes = 16 * 1024
+	classifierTailBytes = 64 * 1024
+)
+
+// Maximum number of lines of an error excerpt
+const excerptMaxLines = 5
+
+var userErrorClassifiers []ErrorClassifier
+
+var defaultErrorClassifiers = []ErrorClassifier{
+	NewPatternClassifier("missing-platform",
+		`(?i)platform.*(cannot be read|not found|does not exist|no such file)|`+
+			`(cannot|unable to) (open|find|read) .*platform`),
+	NewPatternClassifier("zmq-address-in-use",
+		`(?i)address already in use`),
+	signalClassifier("segfault", syscall.SIGSEGV),
+	NewPatternClassifier("segfault", `(?i)segmentation fault`),
+	pythonTracebackClassifier,
+	NewPatternClassifier("protocol-mismatch",
+		`(?i)(protocol|message|event).*(mismatch|unexpected|unknown|`+
+			`not supported|unsupported)|incompatible.*version`),
+	// Processes killed by robin (timeouts, the other process failed...)
+	signalClassifier("terminated", syscall.SIGTERM),
+	signalClassifier("killed", syscall.SIGKILL),
+}
+
+// Registers a classifier, tried before the default classifiers and the
+// classifiers registered before it.
+func RegisterErrorClassifier(classifier ErrorClassifier) {
+	userErrorClassifiers = append([]ErrorClassifier{classifier},
+		userErrorClassifiers...)
+}
+
+// Returns a classifier that recognizes failures whose logs match a regex.
+// The excerpt starts at the first matching line.
+func NewPatternClassifier(category, pattern string) ErrorClassifier {
+	r := regexp.MustCompile(pattern)
+	return func(failure ProcessFailure) (ErrorClassification, bool) {
+		for _, content := range failure.Logs {
+			lines := strings.Split(content, "\n")
+			for i, line := range lines {
+				if r.MatchString(line) {
+					return ErrorClassification{
+						Category: category,
+						Excerpt:  excerpt(lines[i:]),
+					}, true
+				}
+			}
+		}
+		return ErrorClassification{}, false
+	}
+}
+
+func signalClassifier(category string, signal syscall.Signal) ErrorClassifier {
+	return func(failure ProcessFailure) (ErrorClassification, bool) {
+		if failure.Signal == signal {
+			return ErrorClassification{
+				Category: category,
+				Excerpt:  "killed by signal: " + signal.String(),
+			}, true
+		}
+		return ErrorClassification{}, false
+	}
+}
+
+// The interesting part of a Python traceback is at its end
+func pythonTracebackClassifier(failure ProcessFailure) (ErrorClassification,
+	bool) {
+	r := regexp.MustCompile(`^\S*(Error|Exception|Exit|Interrupt)\b.*`)
+	for _, content := range failure.Logs {
+		lines := strings.Split(content, "\n")
+		tracebackLine := -1
+		for i, line := range lines {
+			if strings.HasPrefix(line, "Traceback (most recent call last):") {
+				tracebackLine = i
+			}
+		}
+
+		if tracebackLine != -1 {
+			for i := tracebackLine + 1; i < len(lines); i++ {
+				if r.MatchString(lines[i]) {
+					return ErrorClassification{
+						Category: "python-traceback",
+						Excerpt:  lines[i],
+					}, true
+				}
+			}
+			return ErrorClassification{
+				Category: "python-traceback",
+				Excerpt:  excerpt(lines[tracebackLine:]),
+			}, true
+		}
+	}
+	return ErrorClassification{}, false
+}
+
+func excerpt(lines []string) string {
+	if len(lines) > excerptMaxLines {
+		lines = lines[:excerptMaxLines]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Reads the beginning and the end of a file
+func readLogForClassification(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return ""
+	}
+
+	if info.Size() <= classifierHeadBytes+classifierTailBytes {
+		content, _ := io.ReadAll(file)
+		return string(content)
+	}
+
+	head := make([]byte, classifierHeadBytes)
+	tail := make([]byte, classifierTailBytes)
+	file.ReadAt(head, 0)
+	file.ReadAt(tail, info.Size()-classifierTailBytes)
+	return string(head) + "\n...\n" + string(tail)
+}
+
+// Returns the exit code and the signal of a finished process from the error
+// returned by cmd.Wait.
+// As processes are run by bash, a command killed by signal N makes bash
+// return 128+N: this is also considered as a signal.
+func ExitStatus(err error) (exitCode int, signal syscall.Signal) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return -1, 0
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return exitErr.ExitCode(), 0
+	}
+
+	if status.Signaled() {
+		return -1, status.Signal()
+	}
+
+	exitCode = status.ExitStatus()
+	if exitCode > 128 && exitCode < 128+65 {
+		return exitCode, syscall.Signal(exitCode - 128)
+	}
+	return exitCode, 0
+}
+
+func newProcessFailure(name string, err error,
+	stdoutFile, stderrFile string) ProcessFailure {
+	failure := ProcessFailure{Name: name, Err: err}
+	failure.ExitCode, failure.Signal = ExitStatus(err)
+
+	for _, filename := range []string{stderrFile, stdoutFile} {
+		if filename != "" && filename != "/dev/null" {
+			failure.LogFiles = append(failure.LogFiles, filename)
+			failure.Logs = append(failure.Logs,
+				readLogForClassification(filename))
+		}
+	}
+
+	return failure
+}
+
+// Classifies a process failure with the registered classifiers, then with
+// the default ones. The category is UnknownErrorCategory if no classifier
+// recognizes the failure.
+func ClassifyError(failure ProcessFailure) ErrorClassification {
+	classifiers := append(append([]ErrorClassifier{}, userErrorClassifiers...),
+		defaultErrorClassifiers...)
+	for _, classifier := range classifiers {
+		if classification, ok := classifier(failure); ok {
+			return classification
+		}
+	}
+
+	return ErrorClassification{Category: UnknownErrorCategory}
+}
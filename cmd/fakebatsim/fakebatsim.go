@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	docopt "github.com/docopt/docopt-go"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	version string
+)
+
+const usage = `Fakebatsim mimics Batsim, to test robin without Batsim nor a scheduler.
+
+Usage:
+  fakebatsim [-p <file>] [(-w <file>)...] [(-W <file>)...] [-e <prefix>]
+             [-s <endpoint>] [--batexec] [--dump-execution-context]
+             [--fake-duration=<time>] [--fake-jobs=<n>]
+             [(--fake-exit-code=<n> | --fake-crash=<signal> | --fake-hang)]
+             [--fake-open-socket]
+  fakebatsim -h | --help
+  fakebatsim --version
+
+Batsim options:
+  -p, --platform <file>            Ignored.
+  -w, --workload <file>            Ignored.
+  -W, --workflow <file>            Ignored.
+  -e, --export <prefix>            Export prefix [default: out].
+  -s, --socket-endpoint <endpoint> Socket endpoint
+                                   [default: tcp://localhost:28000].
+  --batexec                        Run without external scheduler.
+  --dump-execution-context         Print the execution context in JSON.
+
+Fake options:
+  --fake-duration=<time>    Run duration in seconds [default: 0.1].
+  --fake-jobs=<n>           Number of jobs logged as submitted then
+                            completed during the run [default: 4].
+  --fake-exit-code=<n>      Exit code at the end of the run [default: 0].
+  --fake-crash=<signal>     Be killed by <signal> (SEGV, ABRT, KILL...) at
+                            the end of the run instead of exiting.
+  --fake-hang               Never end the run.
+  --fake-open-socket        Listen on the TCP port of the socket endpoint
+                            during the run.
+
+The export files (<prefix>_jobs.csv and <prefix>_schedule.csv) are written
+at the end of successful runs.`
+
+// What the fake Batsim has been asked to do
+type fakeArgs struct {
+	batexpe.BatsimArgs
+	Dump       bool
+	Duration   float64
+	Jobs       int
+	ExitCode   int
+	Crash      string
+	Hang       bool
+	OpenSocket bool
+}
+
+// Retrieves what the fake Batsim should do from its parsed arguments
+func fakeArgsFromArgs(arguments map[string]interface{}) (fakeArgs, error) {
+	var fake fakeArgs
+	var err error
+
+	if arguments["--platform"] != nil {
+		fake.Platform = arguments["--platform"].(string)
+	}
+	fake.Workloads = arguments["--workload"].([]string)
+	fake.Workflows = arguments["--workflow"].([]string)
+	fake.ExportPrefix = arguments["--export"].(string)
+	fake.Socket = arguments["--socket-endpoint"].(string)
+	fake.BatexecMode = arguments["--batexec"] == true
+	fake.Dump = arguments["--dump-execution-context"] == true
+	fake.Hang = arguments["--fake-hang"] == true
+	fake.OpenSocket = arguments["--fake-open-socket"] == true
+	if arguments["--fake-crash"] != nil {
+		fake.Crash = arguments["--fake-crash"].(string)
+	}
+
+	fake.Duration, err = strconv.ParseFloat(
+		arguments["--fake-duration"].(string), 64)
+	if err != nil || fake.Duration < 0 {
+		return fake, fmt.Errorf("Invalid duration '%s'",
+			arguments["--fake-duration"])
+	}
+	fake.Jobs, err = strconv.Atoi(arguments["--fake-jobs"].(string))
+	if err != nil || fake.Jobs < 0 {
+		return fake, fmt.Errorf("Invalid number of jobs '%s'",
+			arguments["--fake-jobs"])
+	}
+	fake.ExitCode, err = strconv.Atoi(arguments["--fake-exit-code"].(string))
+	if err != nil {
+		return fake, fmt.Errorf("Invalid exit code '%s'",
+			arguments["--fake-exit-code"])
+	}
+
+	return fake, nil
+}
+
+// Prints the execution context as Batsim does with --dump-execution-context
+func dumpExecutionContext(fake fakeArgs) error {
+	context := map[string]interface{}{
+		"socket_endpoint":                fake.Socket,
+		"export_prefix":                  fake.ExportPrefix,
+		"external_scheduler":             !fake.BatexecMode,
+		"forward_profiles_on_submission": false,
+		"dynamic_jobs_enabled":           false,
+		"dynamic_jobs_acknowledged":      false,
+		"profile_reuse_enabled":          false,
+		"redis": map[string]interface{}{
+			"enabled":  false,
+			"hostname": "127.0.0.1",
+			"port":     6379,
+			"prefix":   "default",
+		},
+	}
+
+	byt, err := json.Marshal(context)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(byt))
+	return nil
+}
+
+// Writes the export files of a successful run
+func writeExports(fake fakeArgs) error {
+	jobs := "job_id,workload_name,submission_time,starting_time," +
+		"finish_time,success\n"
+	for job := 1; job <= fake.Jobs; job++ {
+		jobs += fmt.Sprintf("%d,w0,%d,%d,%d,1\n", job, job, job, job+1)
+	}
+	err := ioutil.WriteFile(fake.ExportPrefix+"_jobs.csv", []byte(jobs), 0644)
+	if err != nil {
+		return err
+	}
+
+	schedule := fmt.Sprintf("batsim_version,nb_jobs,nb_jobs_finished,"+
+		"nb_jobs_success,nb_jobs_killed,makespan\nfake,%d,%d,%d,0,%d\n",
+		fake.Jobs, fake.Jobs, fake.Jobs, fake.Jobs+1)
+	return ioutil.WriteFile(fake.ExportPrefix+"_schedule.csv",
+		[]byte(schedule), 0644)
+}
+
+// Makes the process be killed by a signal.
+// The Go runtime handles signals sent to itself, so the process is replaced
+// by a shell that kills itself.
+func crash(signal string) error {
+	signal = strings.TrimPrefix(strings.ToUpper(signal), "SIG")
+	return syscall.Exec("/bin/sh", []string{"sh", "-c", "kill -" + signal +
+		" $$"}, os.Environ())
+}
+
+// Mimics a simulation and returns the exit code
+func run(fake fakeArgs) int {
+	logf := func(format string, simTime float64, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "[node:batsim:(1) %f] [batsim/INFO] "+format+
+			"\n", append([]interface{}{simTime}, args...)...)
+	}
+
+	if fake.OpenSocket {
+		port, err := batexpe.PortFromBatSock(fake.Socket)
+		if err == nil {
+			var listener net.Listener
+			listener, err = net.Listen("tcp", ":"+strconv.Itoa(int(port)))
+			if err == nil {
+				defer listener.Close()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot open socket %s: %s\n",
+				fake.Socket, err)
+			return 1
+		}
+	}
+
+	logf("Simulation is starting", 0)
+
+	// The jobs are logged regularly during the run
+	step := time.Duration(fake.Duration*1e9) * time.Nanosecond
+	if fake.Jobs > 0 {
+		step /= time.Duration(2 * fake.Jobs)
+	}
+	for job := 1; job <= fake.Jobs; job++ {
+		time.Sleep(step)
+		logf("Job 'w0!%d' has been submitted", float64(job), job)
+		time.Sleep(step)
+		logf("Job 'w0!%d' has been completed", float64(job+1), job)
+	}
+	if fake.Jobs <= 0 {
+		time.Sleep(step)
+	}
+
+	if fake.Hang {
+		for {
+			time.Sleep(time.Hour)
+		}
+	}
+
+	if fake.Crash != "" {
+		err := crash(fake.Crash)
+		fmt.Fprintf(os.Stderr, "Cannot crash: %s\n", err)
+		return 1
+	}
+
+	if fake.ExitCode == 0 {
+		if err := writeExports(fake); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot write exports: %s\n", err)
+			return 1
+		}
+		logf("Simulation is finished", float64(fake.Jobs+1))
+	}
+	return fake.ExitCode
+}
+
+func main() {
+	os.Exit(mainReturnWithCode())
+}
+
+func mainReturnWithCode() int {
+	fakebatsimVersion := version
+	if fakebatsimVersion == "" {
+		fakebatsimVersion = batexpe.Version()
+	}
+
+	ret := -1
+
+	parser := &docopt.Parser{
+		HelpHandler: func(err error, usage string) {
+			fmt.Println(usage)
+			if err != nil {
+				ret = 1
+			} else {
+				ret = 0
+			}
+		},
+	}
+
+	arguments, _ := parser.ParseArgs(usage, os.Args[1:], fakebatsimVersion)
+	if ret != -1 {
+		return ret
+	}
+
+	fake, err := fakeArgsFromArgs(arguments)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if fake.Dump {
+		if err := dumpExecutionContext(fake); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	return run(fake)
+}
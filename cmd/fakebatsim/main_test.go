@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	docopt "github.com/docopt/docopt-go"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Greatly inspired from the following link.
+// https://www.cyphar.com/blog/post/20170412-golang-integration-coverage
+func TestRunMain(t *testing.T) {
+	var (
+		args []string
+	)
+
+	for _, arg := range os.Args {
+		switch {
+		case strings.HasPrefix(arg, "-test"):
+		case strings.HasPrefix(arg, "__bypass"):
+			args = append(args, strings.TrimPrefix(arg, "__bypass"))
+		default:
+			args = append(args, arg)
+		}
+	}
+
+	// Without arguments, a simulation would write its exports here
+	if len(args) == 1 {
+		t.Skip("No fakebatsim arguments")
+	}
+	os.Args = args
+
+	// To retrieve coverage results, os.Exit must NOT be called
+	returnCode := mainReturnWithCode()
+	fmt.Println("Fakebatsim return code:", returnCode)
+}
+
+// Parses a command line as fakebatsim does
+func parseArgs(args []string) (fakeArgs, error) {
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+	arguments, err := parser.ParseArgs(usage, args, "")
+	if err != nil {
+		return fakeArgs{}, err
+	}
+	return fakeArgsFromArgs(arguments)
+}
+
+func TestFakeArgsFromArgs(t *testing.T) {
+	defaults := func() fakeArgs {
+		fake := fakeArgs{Duration: 0.1, Jobs: 4}
+		fake.ExportPrefix = "out"
+		fake.Socket = "tcp://localhost:28000"
+		fake.Workloads = []string{}
+		fake.Workflows = []string{}
+		return fake
+	}
+
+	tests := []struct {
+		name     string
+		args     string
+		expected func(fake *fakeArgs)
+	}{
+		{"defaults", "", func(fake *fakeArgs) {}},
+		{"export", "-e /tmp/prefix", func(fake *fakeArgs) {
+			fake.ExportPrefix = "/tmp/prefix"
+		}},
+		{"export-attached", "-e/tmp/prefix", func(fake *fakeArgs) {
+			fake.ExportPrefix = "/tmp/prefix"
+		}},
+		{"export-long", "--export=/tmp/prefix", func(fake *fakeArgs) {
+			fake.ExportPrefix = "/tmp/prefix"
+		}},
+		{"socket", "-s tcp://localhost:28042 --batexec", func(fake *fakeArgs) {
+			fake.Socket = "tcp://localhost:28042"
+			fake.BatexecMode = true
+		}},
+		{"socket-long", "--socket-endpoint tcp://localhost:28042",
+			func(fake *fakeArgs) { fake.Socket = "tcp://localhost:28042" }},
+		{"inputs", "-p p.xml -w w1.json --workload=w2.json -W wf.dax",
+			func(fake *fakeArgs) {
+				fake.Platform = "p.xml"
+				fake.Workloads = []string{"w1.json", "w2.json"}
+				fake.Workflows = []string{"wf.dax"}
+			}},
+		{"dump", "--dump-execution-context", func(fake *fakeArgs) {
+			fake.Dump = true
+		}},
+		{"failure", "--fake-exit-code=3 --fake-duration=2 --fake-jobs=0",
+			func(fake *fakeArgs) {
+				fake.ExitCode = 3
+				fake.Duration = 2
+				fake.Jobs = 0
+			}},
+		{"crash", "--fake-crash=SEGV", func(fake *fakeArgs) {
+			fake.Crash = "SEGV"
+		}},
+		{"stall", "--fake-hang --fake-open-socket", func(fake *fakeArgs) {
+			fake.Hang = true
+			fake.OpenSocket = true
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, err := parseArgs(strings.Fields(test.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := defaults()
+			test.expected(&expected)
+			if !reflect.DeepEqual(fake, expected) {
+				t.Errorf("Unexpected arguments:\nexpected %+v\ngot      %+v",
+					expected, fake)
+			}
+		})
+	}
+}
+
+func TestFakeArgsFromArgsFailure(t *testing.T) {
+	for _, args := range []string{
+		"--fake-jobs=x",
+		"--fake-jobs=-1",
+		"--fake-duration=-1",
+		"--fake-exit-code=x",
+		"--fake-crash=SEGV --fake-hang",
+		"--fake-exit-code=1 --fake-crash=SEGV",
+		"--unknown-option",
+		"-e",
+	} {
+		if _, err := parseArgs(strings.Fields(args)); err == nil {
+			t.Errorf("Arguments '%s' accepted", args)
+		}
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	fake, err := parseArgs([]string{"-e", filepath.Join(t.TempDir(), "out"),
+		"--fake-exit-code=3", "--fake-duration=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := run(fake); code != 3 {
+		t.Errorf("Unexpected exit code: %d", code)
+	}
+	if _, err := os.Stat(fake.ExportPrefix + "_jobs.csv"); err == nil {
+		t.Errorf("Export files written by a failed run")
+	}
+}
+
+func TestRunSuccess(t *testing.T) {
+	fake, err := parseArgs([]string{"-e", filepath.Join(t.TempDir(), "out"),
+		"--fake-duration=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := run(fake); code != 0 {
+		t.Errorf("Unexpected exit code: %d", code)
+	}
+	for _, suffix := range []string{"_jobs.csv", "_schedule.csv"} {
+		if _, err := os.Stat(fake.ExportPrefix + suffix); err != nil {
+			t.Errorf("Export file not written: %s", err)
+		}
+	}
+}
+
+// Starts the test binary as fakebatsim (see TestRunMain)
+func startFakeBatsim(t *testing.T, args ...string) *exec.Cmd {
+	cmdArgs := []string{"-test.run=^TestRunMain$"}
+	for _, arg := range args {
+		cmdArgs = append(cmdArgs, "__bypass"+arg)
+	}
+
+	cmd := exec.Command(os.Args[0], cmdArgs...)
+	cmd.Dir = t.TempDir()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestRunCrash(t *testing.T) {
+	cmd := startFakeBatsim(t, "--fake-duration=0", "--fake-crash=ABRT")
+	err := cmd.Wait()
+
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGABRT {
+		t.Errorf("Unexpected termination: %v", err)
+	}
+}
+
+func TestRunHang(t *testing.T) {
+	cmd := startFakeBatsim(t, "--fake-duration=0", "--fake-hang")
+	finished := make(chan error, 1)
+	go func() { finished <- cmd.Wait() }()
+
+	select {
+	case err := <-finished:
+		t.Errorf("Hanging run finished: %v", err)
+	case <-time.After(500 * time.Millisecond):
+		cmd.Process.Kill()
+		<-finished
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Lucas-Doctorate-Project/batexpe"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
@@ -117,6 +118,94 @@ func ExperimentFromArgs(arguments map[string]interface{}) (batexpe.Experiment,
 		}
 	}
 
+	if arguments["--total-timeout"] != nil {
+		exp.TotalTimeout, err = strconv.ParseFloat(
+			arguments["--total-timeout"].(string), 64)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":             err,
+				"--total-timeout": arguments["--total-timeout"].(string),
+			}).Error("Invalid total timeout")
+			return exp, fmt.Errorf("Invalid total timeout")
+		}
+	}
+
+	if arguments["--stall-timeout"] != nil {
+		exp.StallTimeout, err = strconv.ParseFloat(
+			arguments["--stall-timeout"].(string), 64)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":             err,
+				"--stall-timeout": arguments["--stall-timeout"].(string),
+			}).Error("Invalid stall timeout")
+			return exp, fmt.Errorf("Invalid stall timeout")
+		}
+	}
+
+	if arguments["--log-max-size"] != nil {
+		exp.LogMaxSize, err = batexpe.ParseSize(
+			arguments["--log-max-size"].(string))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":            err,
+				"--log-max-size": arguments["--log-max-size"].(string),
+			}).Error("Invalid log max size")
+			return exp, fmt.Errorf("Invalid log max size")
+		}
+	}
+
+	if arguments["--log-rotate"] != nil {
+		exp.LogRotate, err = strconv.Atoi(arguments["--log-rotate"].(string))
+		if err != nil || exp.LogRotate < 0 {
+			log.WithFields(log.Fields{
+				"err":          err,
+				"--log-rotate": arguments["--log-rotate"].(string),
+			}).Error("Invalid number of rotated logs")
+			return exp, fmt.Errorf("Invalid number of rotated logs")
+		}
+	}
+
+	exp.LogCompress = arguments["--log-compress"] == true
+	exp.CoreDumps = arguments["--core-dumps"] == true
+	exp.CoreBacktrace = arguments["--core-backtrace"] == true
+
+	if arguments["--retries"] != nil {
+		exp.Retries, err = strconv.Atoi(arguments["--retries"].(string))
+		if err != nil || exp.Retries < 0 {
+			log.WithFields(log.Fields{
+				"err":       err,
+				"--retries": arguments["--retries"].(string),
+			}).Error("Invalid number of retries")
+			return exp, fmt.Errorf("Invalid number of retries")
+		}
+	}
+
+	if arguments["--retry-backoff"] != nil {
+		exp.RetryBackoff, err = strconv.ParseFloat(
+			arguments["--retry-backoff"].(string), 64)
+		if err != nil || exp.RetryBackoff < 0 {
+			log.WithFields(log.Fields{
+				"err":             err,
+				"--retry-backoff": arguments["--retry-backoff"].(string),
+			}).Error("Invalid retry backoff")
+			return exp, fmt.Errorf("Invalid retry backoff")
+		}
+	}
+
+	if arguments["--retry-on"] != nil {
+		exp.RetryOn = batexpe.SplitList(arguments["--retry-on"].(string))
+	}
+
+	if arguments["--on-existing-output"] != nil {
+		exp.OnExistingOutput = arguments["--on-existing-output"].(string)
+	}
+
+	if arguments["--strict-export-prefix"] == true {
+		exp.ExportPrefixPolicy = batexpe.ExportPrefixStrict
+	} else if arguments["--inject-export-prefix"] == true {
+		exp.ExportPrefixPolicy = batexpe.ExportPrefixInject
+	}
+
 	log.WithFields(log.Fields{
 		"args": arguments,
 		"expe": exp,
@@ -125,6 +214,80 @@ func ExperimentFromArgs(arguments map[string]interface{}) (batexpe.Experiment,
 	return exp, nil
 }
 
+func ExecuteOptionsFromArgs(arguments map[string]interface{},
+	previewOnError bool) (batexpe.ExecuteOptions, error) {
+	var opts batexpe.ExecuteOptions
+	var err error
+
+	opts.PreviewOnError = previewOnError
+	opts.LiveStatus = arguments["--live-status"] == true
+	opts.Tee = arguments["--tee"] == true
+	opts.TeeColor = arguments["--tee-color"] == true
+	opts.DryRun = arguments["--dry-run"] == true
+
+	if arguments["--debug-process"] != nil {
+		opts.DebugProcess = arguments["--debug-process"].(string)
+	}
+	if arguments["--debug-wrapper"] != nil {
+		opts.DebugWrapper = arguments["--debug-wrapper"].(string)
+	}
+
+	if arguments["--progress"] != nil {
+		opts.ProgressInterval, err = strconv.ParseFloat(
+			arguments["--progress"].(string), 64)
+		if err != nil || opts.ProgressInterval <= 0 {
+			log.WithFields(log.Fields{
+				"err":        err,
+				"--progress": arguments["--progress"].(string),
+			}).Error("Invalid progress period")
+			return opts, fmt.Errorf("Invalid progress period")
+		}
+	}
+
+	if opts.LiveStatus && arguments["--json-logs"] == true {
+		log.WithFields(log.Fields{
+			"option": "--live-status",
+		}).Warning("Live status is not printed with JSON logs")
+		opts.LiveStatus = false
+	}
+
+	// Teed lines are not JSON, they would break the parsing of the logs
+	if opts.Tee && arguments["--json-logs"] == true {
+		log.WithFields(log.Fields{
+			"option": "--tee",
+		}).Warning("Process outputs are not teed with JSON logs")
+		opts.Tee = false
+	}
+
+	return opts, nil
+}
+
+func replayExperiment(arguments map[string]interface{}) (batexpe.Experiment,
+	error) {
+	previousOutputDir := arguments["<previous-output-dir>"].(string)
+	outputDir := ""
+	if arguments["--output-dir"] != nil {
+		outputDir = arguments["--output-dir"].(string)
+	}
+
+	exp, err := batexpe.ReplayExperiment(previousOutputDir, outputDir)
+	if err != nil {
+		return exp, err
+	}
+
+	// In-place replay: the previous logs are kept aside and the rest of the
+	// output directory is reused
+	inPlace := filepath.Clean(exp.OutputDir) == filepath.Clean(previousOutputDir)
+	if inPlace {
+		exp.OnExistingOutput = batexpe.OutputPolicyReuse
+		if arguments["--dry-run"] != true {
+			_, err = batexpe.ArchiveRun(previousOutputDir)
+		}
+	}
+
+	return exp, err
+}
+
 func generateDescription(arguments map[string]interface{}) error {
 	exp, err := ExperimentFromArgs(arguments)
 	if err != nil {
@@ -162,11 +325,31 @@ Usage:
         [--ready-timeout=<time>]
         [--success-timeout=<time>]
         [--failure-timeout=<time>]
+        [--stall-timeout=<time>]
+        [--total-timeout=<time>]
+        [--log-max-size=<size> [--log-rotate=<n>]] [--log-compress]
+        [--core-dumps [--core-backtrace]]
+        [--retries=<n> [--retry-backoff=<time>] [--retry-on=<reasons>]]
+        [--on-existing-output=<policy>]
+        [(--strict-export-prefix | --inject-export-prefix)]
         [(--verbose | --quiet | --debug)] [--json-logs]
         [(--no-preview-on-error | --preview-on-error)]
+        [--progress=<time>] [--live-status]
+        [--tee [--tee-color]] [--dry-run]
+        [--debug-process=<name> [--debug-wrapper=<wrapper>]]
   robin <description-file>
         [(--verbose | --quiet | --debug)] [--json-logs]
         [(--no-preview-on-error | --preview-on-error)]
+        [--progress=<time>] [--live-status]
+        [--tee [--tee-color]] [--dry-run]
+        [--debug-process=<name> [--debug-wrapper=<wrapper>]]
+  robin replay <previous-output-dir>
+        [--output-dir=<dir>]
+        [(--verbose | --quiet | --debug)] [--json-logs]
+        [(--no-preview-on-error | --preview-on-error)]
+        [--progress=<time>] [--live-status]
+        [--tee [--tee-color]] [--dry-run]
+        [--debug-process=<name> [--debug-wrapper=<wrapper>]]
   robin generate <description-file>
         [--output-dir=<dir>]
         [--batcmd=<batsim-command>]
@@ -175,6 +358,13 @@ Usage:
         [--ready-timeout=<time>]
         [--success-timeout=<time>]
         [--failure-timeout=<time>]
+        [--stall-timeout=<time>]
+        [--total-timeout=<time>]
+        [--log-max-size=<size> [--log-rotate=<n>]] [--log-compress]
+        [--core-dumps [--core-backtrace]]
+        [--retries=<n> [--retry-backoff=<time>] [--retry-on=<reasons>]]
+        [--on-existing-output=<policy>]
+        [(--strict-export-prefix | --inject-export-prefix)]
         [(--verbose | --quiet | --debug)] [--json-logs]
   robin -h | --help
   robin --version
@@ -187,6 +377,7 @@ Examples:
   robin --output-dir=/tmp \
         --batcmd="batsim -p platform.xml -w workload.json --batexec"
   robin input_description_file.yaml
+  robin replay /tmp/previous-run --output-dir=/tmp/new-run
   robin generate output_description_file.yaml
 
 
@@ -213,13 +404,116 @@ Timeout options:
                                 unsuccessfully.
                                 [default: 5]
 
+  --stall-timeout=<time>        Stall timeout in seconds.
+                                If Batsim's export files and the processes'
+                                logs stop growing for this time, or if the
+                                processes do not consume any CPU for this
+                                time, the simulation is stopped and
+                                considered stalled.
+                                Disabled if unset or set to 0.
+
+  --total-timeout=<time>        Total timeout in seconds.
+                                Caps the whole robin execution, including
+                                the time spent waiting for a valid context.
+                                If this time is exceeded, the simulation is
+                                stopped.
+                                Disabled if unset or set to 0.
+
 Verbosity options:
   --quiet                       Only print critical information.
   --verbose                     Print information. Default verbosity mode.
   --debug                       Print debug information.
   --json-logs                   Print information in JSON.
   --preview-on-error            Preview run logs of failed processes. Default.
-  --no-preview-on-error         Do not preview run logs of failed processes.`
+  --no-preview-on-error         Do not preview run logs of failed processes.
+
+Execution options:
+  --dry-run                     Do everything but starting the processes:
+                                check the description and the context, write
+                                the command files, then print the execution
+                                plan (commands, log files, timeouts, socket
+                                endpoint and export prefix).
+
+Debug options:
+  --debug-process=<name>        Process to debug (Batsim or Scheduler).
+                                Robin does not start it but prints its
+                                command and waits for the user to start it
+                                manually (under gdb, a Python debugger...),
+                                following it via the socket.
+                                Requires a TCP socket and a scheduler.
+  --debug-wrapper=<wrapper>     Start the debugged process in a wrapper
+                                instead: gdb (gdb -batch, printing the
+                                backtrace), valgrind (report in the log
+                                directory) or any command prefix.
+
+Progress options:
+  --progress=<time>             Periodically log the simulation progress
+                                (simulated time, submitted and completed
+                                jobs, log lines per second), as read from
+                                Batsim's log. Period in seconds.
+  --live-status                 Print a live one-line progress status on
+                                stderr, if it is a terminal.
+
+Output options:
+  --tee                         Also stream the outputs of Batsim and of the
+                                scheduler on stdout, each line being prefixed
+                                by the process name.
+                                The log files are still written.
+                                Ignored with --json-logs.
+  --tee-color                   Colorize the streamed outputs.
+
+Output directory options:
+  --on-existing-output=<policy> What to do if the output directory already
+                                exists and is not empty:
+                                fail (do not execute the simulation),
+                                overwrite (remove the files of the
+                                previous robin run first),
+                                archive (move it to output-dir.<timestamp>)
+                                or reuse (keep its files). Default is reuse.
+  --strict-export-prefix        Refuse to execute the simulation if Batsim's
+                                export prefix is not in the output directory.
+  --inject-export-prefix        Add -e <output-dir>/out to the Batsim command
+                                if it sets no export prefix. Refuse to execute
+                                the simulation if the export prefix it sets
+                                is not in the output directory.
+
+Log options:
+  --log-max-size=<size>         Maximum size of each process log file, in
+                                bytes (K, M and G suffixes are supported).
+                                When reached, the log is truncated, or rotated
+                                if --log-rotate is set.
+                                Unlimited if unset or set to 0.
+  --log-rotate=<n>              Number of rotated log files to keep
+                                (log.1, log.2...). Default is 0, which means
+                                that logs are truncated instead.
+  --log-compress                Compress the log files with gzip once the
+                                simulation has finished.
+
+Crash options:
+  --core-dumps                  Enable core dumps for the subprocesses by
+                                raising their core file size limit.
+                                The core files of crashed processes are
+                                moved into output-dir/crash once written
+                                where the system core pattern says.
+  --core-backtrace              Write the backtrace of each collected core
+                                file, if gdb is available.
+
+Retry options:
+  --retries=<n>                 Maximum number of times a failed simulation
+                                is executed again. Default is 0.
+                                The logs of attempt N (N>1) are written in
+                                output-dir/log/attempt-N.
+  --retry-backoff=<time>        Time to wait before the first retry in
+                                seconds, doubled after each retry.
+                                Default is 0.
+  --retry-on=<reasons>          Comma-separated list of the failures that
+                                are retried. Reasons are execution states
+                                (context-invalid, setup-failure, timeout,
+                                failure, stalled) or error categories
+                                (zmq-address-in-use, segfault...).
+                                Default is context-invalid.
+                                Executions stopped by the total timeout
+                                (total-timeout state) are never retried.`
 
 	robinVersion := version
 	if robinVersion == "" {
@@ -270,7 +564,13 @@ Verbosity options:
 	// Execution mode.
 	// Read what should be executed
 	var exp batexpe.Experiment
-	if arguments["<description-file>"] != nil {
+	if arguments["replay"] == true {
+		var err error
+		exp, err = replayExperiment(arguments)
+		if err != nil {
+			return 1
+		}
+	} else if arguments["<description-file>"] != nil {
 		fil := arguments["<description-file>"].(string)
 		byt, err := ioutil.ReadFile(fil)
 		if err != nil {
@@ -301,8 +601,25 @@ Verbosity options:
 		"ready timeout":      exp.ReadyTimeout,
 		"success timeout":    exp.SuccessTimeout,
 		"failure timeout":    exp.FailureTimeout,
+		"stall timeout":      exp.StallTimeout,
+		"total timeout":      exp.TotalTimeout,
+		"log max size":       exp.LogMaxSize,
+		"log rotate":         exp.LogRotate,
+		"log compress":       exp.LogCompress,
+		"core dumps":         exp.CoreDumps,
+		"core backtrace":     exp.CoreBacktrace,
+		"retries":            exp.Retries,
+		"retry backoff":      exp.RetryBackoff,
+		"retry on":           exp.RetryOn,
+		"on existing output": exp.OnExistingOutput,
+		"export prefix":      exp.ExportPrefixPolicy,
 	}).Debug("Instance description read")
 
-	ret = batexpe.ExecuteOne(exp, previewOnError)
+	opts, err := ExecuteOptionsFromArgs(arguments, previewOnError)
+	if err != nil {
+		return 1
+	}
+
+	ret = batexpe.ExecuteOneWithOptions(exp, opts)
 	return ret
 }
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	docopt "github.com/docopt/docopt-go"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	version string
+)
+
+// Signals that can be injected
+var faultSignals = map[string]syscall.Signal{
+	"SEGV": syscall.SIGSEGV,
+	"KILL": syscall.SIGKILL,
+	"STOP": syscall.SIGSTOP,
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"ABRT": syscall.SIGABRT,
+}
+
+// The faults to inject around a command
+type Faults struct {
+	Signal     syscall.Signal
+	At         float64 // Send Signal after this time (in seconds) if > 0
+	AfterBytes int64   // Send Signal after this much output if > 0
+	StartDelay float64 // Wait this time (in seconds) before the command
+	HoldPort   uint16  // Listen on this port if > 0
+	HoldTime   float64 // Release HoldPort after this time if > 0
+	HoldSocket string  // The socket endpoint HoldPort comes from
+}
+
+func setupLogging(arguments map[string]interface{}) {
+	// stdout is the output of the wrapped command
+	log.SetOutput(os.Stderr)
+
+	customFormatter := new(log.TextFormatter)
+	customFormatter.TimestampFormat = "2006-01-02 15:04:05.000"
+	customFormatter.FullTimestamp = true
+	customFormatter.QuoteEmptyFields = true
+	log.SetFormatter(customFormatter)
+
+	if arguments["--debug"] == true {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+}
+
+func parseSignal(name string) (syscall.Signal, error) {
+	signal, exists := faultSignals[strings.TrimPrefix(
+		strings.ToUpper(name), "SIG")]
+	if !exists {
+		return 0, fmt.Errorf("Invalid signal '%s' (expected SEGV, KILL, "+
+			"STOP, TERM, INT or ABRT)", name)
+	}
+	return signal, nil
+}
+
+func parseTime(arguments map[string]interface{}, option string) (float64,
+	error) {
+	if arguments[option] == nil {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseFloat(arguments[option].(string), 64)
+	if err == nil && value < 0 {
+		err = fmt.Errorf("Time is negative")
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":  err,
+			option: arguments[option].(string),
+		}).Error("Invalid time")
+		return 0, fmt.Errorf("Invalid time")
+	}
+	return value, nil
+}
+
+func FaultsFromArgs(arguments map[string]interface{}) (Faults, error) {
+	var faults Faults
+	var err error
+
+	faults.Signal, err = parseSignal(arguments["--signal"].(string))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid signal")
+		return faults, fmt.Errorf("Invalid signal")
+	}
+
+	if faults.At, err = parseTime(arguments, "--at"); err != nil {
+		return faults, err
+	}
+	if faults.StartDelay, err = parseTime(arguments, "--start-delay"); err != nil {
+		return faults, err
+	}
+	if faults.HoldTime, err = parseTime(arguments, "--hold-time"); err != nil {
+		return faults, err
+	}
+
+	if arguments["--after-bytes"] != nil {
+		faults.AfterBytes, err = strconv.ParseInt(
+			arguments["--after-bytes"].(string), 10, 64)
+		if err == nil && faults.AfterBytes <= 0 {
+			err = fmt.Errorf("Number of bytes is not positive")
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":           err,
+				"--after-bytes": arguments["--after-bytes"].(string),
+			}).Error("Invalid number of bytes")
+			return faults, fmt.Errorf("Invalid number of bytes")
+		}
+	}
+
+	if arguments["--hold-port"] != nil {
+		faults.HoldSocket = arguments["--hold-port"].(string)
+		faults.HoldPort, err = batexpe.PortFromBatSock(faults.HoldSocket)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":         err,
+				"--hold-port": faults.HoldSocket,
+			}).Error("Invalid socket endpoint")
+			return faults, fmt.Errorf("Invalid socket endpoint")
+		}
+	}
+
+	return faults, nil
+}
+
+// Counts the bytes written by the command on its outputs
+type outputCounter struct {
+	mutex     sync.Mutex
+	written   int64
+	threshold int64
+	reached   chan bool
+}
+
+func newOutputCounter(threshold int64) *outputCounter {
+	return &outputCounter{
+		threshold: threshold,
+		reached:   make(chan bool),
+	}
+}
+
+func (counter *outputCounter) add(n int) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	before := counter.written
+	counter.written += int64(n)
+	if counter.threshold > 0 && before < counter.threshold &&
+		counter.written >= counter.threshold {
+		close(counter.reached)
+	}
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	counter *outputCounter
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.counter.add(n)
+	return n, err
+}
+
+// Listens on the held port until release is closed or HoldTime is elapsed
+func holdPort(faults Faults, release chan bool) error {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(int(faults.HoldPort)))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":  err,
+			"port": faults.HoldPort,
+		}).Error("Cannot hold port")
+		return fmt.Errorf("Cannot hold port")
+	}
+
+	log.WithFields(log.Fields{
+		"port":            faults.HoldPort,
+		"hold time":       faults.HoldTime,
+		"socket endpoint": faults.HoldSocket,
+	}).Info("Port held")
+
+	go func() {
+		if faults.HoldTime > 0 {
+			select {
+			case <-release:
+			case <-time.After(time.Duration(faults.HoldTime*1e9) *
+				time.Nanosecond):
+			}
+		} else {
+			<-release
+		}
+		listener.Close()
+		log.WithFields(log.Fields{
+			"port": faults.HoldPort,
+		}).Info("Port released")
+	}()
+	return nil
+}
+
+// Sends the fault signal to the command once its trigger is reached
+func injectFault(faults Faults, cmd *exec.Cmd, counter *outputCounter,
+	finished chan bool) {
+	var timer <-chan time.Time
+	if faults.At > 0 {
+		timer = time.After(time.Duration(faults.At*1e9) * time.Nanosecond)
+	}
+
+	trigger := "time"
+	select {
+	case <-finished:
+		return
+	case <-timer:
+	case <-counter.reached:
+		trigger = "output size"
+	}
+
+	log.WithFields(log.Fields{
+		"signal":  faults.Signal,
+		"pid":     cmd.Process.Pid,
+		"trigger": trigger,
+	}).Warn("Injecting fault")
+
+	err := cmd.Process.Signal(faults.Signal)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+			"pid": cmd.Process.Pid,
+		}).Error("Cannot send signal")
+	}
+}
+
+// Forwards the termination signals received to the command.
+// The command is continued, so that it can handle them even if it has been
+// stopped by an injected SIGSTOP.
+func forwardSignals(cmd *exec.Cmd, finished chan bool) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(received)
+
+	for {
+		select {
+		case <-finished:
+			return
+		case sig := <-received:
+			log.WithFields(log.Fields{
+				"signal": sig,
+				"pid":    cmd.Process.Pid,
+			}).Debug("Forwarding signal")
+			cmd.Process.Signal(sig)
+			cmd.Process.Signal(syscall.SIGCONT)
+		}
+	}
+}
+
+// Runs a command with the faults injected around it.
+// Returns the exit code of the command. As bash, 128+N is returned if the
+// command has been killed by signal N.
+func RunWithFaults(command []string, faults Faults) int {
+	release := make(chan bool)
+	defer close(release)
+
+	if faults.HoldPort > 0 {
+		if err := holdPort(faults, release); err != nil {
+			return 1
+		}
+	}
+
+	if faults.StartDelay > 0 {
+		log.WithFields(log.Fields{
+			"start delay": faults.StartDelay,
+		}).Info("Delaying command start")
+		time.Sleep(time.Duration(faults.StartDelay*1e9) * time.Nanosecond)
+	}
+
+	counter := newOutputCounter(faults.AfterBytes)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &countingWriter{writer: os.Stdout, counter: counter}
+	cmd.Stderr = &countingWriter{writer: os.Stderr, counter: counter}
+
+	if err := cmd.Start(); err != nil {
+		log.WithFields(log.Fields{
+			"err":     err,
+			"command": strings.Join(command, " "),
+		}).Error("Could not start command")
+		return 1
+	}
+
+	log.WithFields(log.Fields{
+		"command": strings.Join(command, " "),
+		"pid":     cmd.Process.Pid,
+	}).Debug("Command started")
+
+	finished := make(chan bool)
+	go forwardSignals(cmd, finished)
+	if faults.At > 0 || faults.AfterBytes > 0 {
+		go injectFault(faults, cmd, counter, finished)
+	}
+
+	err := cmd.Wait()
+	close(finished)
+	if err == nil {
+		return 0
+	}
+
+	exitCode, signal := batexpe.ExitStatus(err)
+	if signal != 0 {
+		log.WithFields(log.Fields{
+			"signal": signal,
+		}).Info("Command killed by signal")
+		return 128 + int(signal)
+	}
+	if exitCode == -1 {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot retrieve command exit code")
+		return 1
+	}
+	return exitCode
+}
+
+func main() {
+	os.Exit(mainReturnWithCode())
+}
+
+func mainReturnWithCode() int {
+	usage := `Robinfault injects faults around any command (e.g. a scheduler).
+
+Usage:
+  robinfault [--signal=<signal>] [(--at=<time> | --after-bytes=<n>)]
+             [--start-delay=<time>]
+             [--hold-port=<socket> [--hold-time=<time>]]
+             [--debug] [--] <command>...
+  robinfault -h | --help
+  robinfault --version
+
+Examples:
+  robinfault --signal=SEGV --at=2 -- batsched -v easy_bf
+  robinfault --signal=STOP --after-bytes=4096 batsched
+  robinfault --hold-port=tcp://localhost:28000 --hold-time=3 batsched
+
+Fault options:
+  --signal=<signal>     Signal sent to the command: SEGV, KILL, STOP, TERM,
+                        INT or ABRT [default: SEGV].
+  --at=<time>           Send the signal after <time> seconds.
+  --after-bytes=<n>     Send the signal once the command has written <n>
+                        bytes on its outputs (stdout and stderr).
+  --start-delay=<time>  Wait <time> seconds before starting the command.
+  --hold-port=<socket>  Listen on the TCP port of the <socket> endpoint
+                        before starting the command, so that the port is
+                        already in use.
+  --hold-time=<time>    Release the held port after <time> seconds.
+                        By default, the port is held until the command
+                        finishes.
+
+Robinfault returns the exit code of the command, or 128+N if the command has
+been killed by signal N. Its logs are printed on stderr.`
+
+	robinfaultVersion := version
+	if robinfaultVersion == "" {
+		robinfaultVersion = batexpe.Version()
+	}
+
+	ret := -1
+
+	parser := &docopt.Parser{
+		HelpHandler: func(err error, usage string) {
+			fmt.Println(usage)
+			if err != nil {
+				ret = 1
+			} else {
+				ret = 0
+			}
+		},
+		// Options of the command are not robinfault's
+		OptionsFirst: true,
+	}
+
+	arguments, _ := parser.ParseArgs(usage, os.Args[1:], robinfaultVersion)
+	if ret != -1 {
+		return ret
+	}
+
+	setupLogging(arguments)
+
+	log.WithFields(log.Fields{
+		"args": arguments,
+	}).Debug("Arguments parsed")
+
+	faults, err := FaultsFromArgs(arguments)
+	if err != nil {
+		return 1
+	}
+
+	return RunWithFaults(arguments["<command>"].([]string), faults)
+}
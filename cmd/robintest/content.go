@@ -0,0 +1,293 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expected value in an output file: <file-suffix>:<key>=<value>
+type valueExpectation struct {
+	FileSuffix string
+	Key        string
+	Value      string
+}
+
+// Expected content of a process log: <process>:<regex>
+type logExpectation struct {
+	Process string
+	Regexp  *regexp.Regexp
+}
+
+func parseValueExpectation(spec string) (valueExpectation, error) {
+	colon := strings.Index(spec, ":")
+	equal := strings.Index(spec, "=")
+	if colon <= 0 || equal <= colon+1 {
+		return valueExpectation{}, fmt.Errorf("Invalid value expectation "+
+			"'%s' (expected <file-suffix>:<key>=<value>)", spec)
+	}
+
+	return valueExpectation{
+		FileSuffix: spec[:colon],
+		Key:        spec[colon+1 : equal],
+		Value:      spec[equal+1:],
+	}, nil
+}
+
+func parseLogExpectation(spec string) (logExpectation, error) {
+	colon := strings.Index(spec, ":")
+	if colon == -1 {
+		return logExpectation{}, fmt.Errorf("Invalid log expectation "+
+			"'%s' (expected <process>:<regex>)", spec)
+	}
+
+	process := spec[:colon]
+	if process != "Batsim" && process != "Scheduler" {
+		return logExpectation{}, fmt.Errorf("Invalid process '%s' in log "+
+			"expectation (expected Batsim or Scheduler)", process)
+	}
+
+	r, err := regexp.Compile(spec[colon+1:])
+	if err != nil {
+		return logExpectation{}, fmt.Errorf("Invalid regex in log "+
+			"expectation '%s': %s", spec, err)
+	}
+	return logExpectation{Process: process, Regexp: r}, nil
+}
+
+// Checks the syntax of the content expectations
+func checkContentExpectations(expect Expectations) error {
+	for _, specs := range [][]string{expect.CSVValues, expect.JSONValues} {
+		for _, spec := range specs {
+			if _, err := parseValueExpectation(spec); err != nil {
+				return err
+			}
+		}
+	}
+	for _, specs := range [][]string{expect.LogMatches, expect.LogNoMatches} {
+		for _, spec := range specs {
+			if _, err := parseLogExpectation(spec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the values of a column of a CSV file with a header
+func readCSVColumn(filename, column string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("No data row in %s", filename)
+	}
+
+	index := -1
+	for i, name := range rows[0] {
+		if name == column {
+			index = i
+		}
+	}
+	if index == -1 {
+		return nil, fmt.Errorf("No column '%s' in %s", column, filename)
+	}
+
+	values := make([]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		values = append(values, row[index])
+	}
+	return values, nil
+}
+
+// Returns the value of a JSON file at a dot-separated key path.
+// Array elements are accessed by their index.
+func readJSONValue(filename, key string) (string, error) {
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(byt, &value); err != nil {
+		return "", err
+	}
+
+	for _, part := range strings.Split(key, ".") {
+		switch object := value.(type) {
+		case map[string]interface{}:
+			child, exists := object[part]
+			if !exists {
+				return "", fmt.Errorf("No key '%s' in %s", key, filename)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(object) {
+				return "", fmt.Errorf("No key '%s' in %s", key, filename)
+			}
+			value = object[index]
+		default:
+			return "", fmt.Errorf("No key '%s' in %s", key, filename)
+		}
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// Reads a log file, or its compressed version if it has been compressed
+func readLog(filename string) (string, error) {
+	if !strings.HasSuffix(filename, ".gz") {
+		byt, err := ioutil.ReadFile(filename)
+		if err == nil || !os.IsNotExist(err) {
+			return string(byt), err
+		}
+		if _, gzErr := os.Stat(filename + ".gz"); gzErr != nil {
+			return "", err
+		}
+		filename += ".gz"
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	byt, err := ioutil.ReadAll(reader)
+	return string(byt), err
+}
+
+// Reads a log file preceded by its rotated files, from the oldest one
+func readRotatedLog(filename string) (string, error) {
+	rotated := batexpe.RotatedLogFiles(filename)
+
+	var content strings.Builder
+	for i := len(rotated) - 1; i >= 0; i-- {
+		rotatedContent, err := readLog(rotated[i])
+		if err != nil {
+			return "", err
+		}
+		content.WriteString(rotatedContent)
+	}
+
+	liveContent, err := readLog(filename)
+	if err != nil {
+		return "", err
+	}
+	content.WriteString(liveContent)
+	return content.String(), nil
+}
+
+// Returns the content of the logs of a process
+func readProcessLogs(logDir string, process string) (string, error) {
+	filenames := []string{logDir + "/batsim.log"}
+	if process == "Scheduler" {
+		filenames = []string{logDir + "/sched.out.log",
+			logDir + "/sched.err.log"}
+	}
+
+	var content strings.Builder
+	for _, filename := range filenames {
+		fileContent, err := readRotatedLog(filename)
+		if err != nil {
+			return "", err
+		}
+		content.WriteString(fileContent)
+	}
+	return content.String(), nil
+}
+
+// Checks the files produced by a robin execution
+func (result *TestResult) checkContent(run executedRun,
+	expect Expectations) {
+	batargs := run.Batargs
+	for _, suffix := range expect.ExportFiles {
+		filename := batargs.ExportPrefix + suffix
+		_, err := os.Stat(filename)
+		result.check("existence of "+filename, true, err == nil)
+	}
+
+	for _, spec := range expect.CSVValues {
+		expectation, _ := parseValueExpectation(spec)
+		filename := batargs.ExportPrefix + expectation.FileSuffix
+		name := fmt.Sprintf("CSV value %s:%s", filename, expectation.Key)
+
+		values, err := readCSVColumn(filename, expectation.Key)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot read CSV value")
+			result.fail(name, err)
+			continue
+		}
+
+		// All the rows must have the expected value
+		got := expectation.Value
+		for _, value := range values {
+			if value != expectation.Value {
+				got = value
+				break
+			}
+		}
+		result.check(name, expectation.Value, got)
+	}
+
+	for _, spec := range expect.JSONValues {
+		expectation, _ := parseValueExpectation(spec)
+		filename := batargs.ExportPrefix + expectation.FileSuffix
+		name := fmt.Sprintf("JSON value %s:%s", filename, expectation.Key)
+
+		value, err := readJSONValue(filename, expectation.Key)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Cannot read JSON value")
+			result.fail(name, err)
+			continue
+		}
+		result.check(name, expectation.Value, value)
+	}
+
+	checkLogs := func(specs []string, expectMatch bool) {
+		for _, spec := range specs {
+			expectation, _ := parseLogExpectation(spec)
+			name := fmt.Sprintf("%s log match of '%s'", expectation.Process,
+				expectation.Regexp)
+
+			content, err := readProcessLogs(run.LogDir, expectation.Process)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Error("Cannot read process log")
+				result.fail(name, err)
+				continue
+			}
+			result.check(name, expectMatch,
+				expectation.Regexp.MatchString(content))
+		}
+	}
+	checkLogs(expect.LogMatches, true)
+	checkLogs(expect.LogNoMatches, false)
+}
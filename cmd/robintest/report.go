@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// One JUnit test suite per robin test
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      float64         `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	SystemOut *junitOutput    `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+// One JUnit test case per expectation check
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Expected string `xml:"expected,attr"`
+	Got      string `xml:"got,attr"`
+}
+
+func checkMessage(check CheckResult) string {
+	return fmt.Sprintf("expected %v, got %v", check.Expected, check.Got)
+}
+
+// Builds the JUnit report of robin tests.
+// The output of robin is kept in the system-out of each test suite.
+func newJUnitReport(results []CaseResult) junitTestSuites {
+	var report junitTestSuites
+	for _, caseResult := range results {
+		suite := junitTestSuite{
+			Name: caseResult.Case.Name,
+			Time: caseResult.Result.Duration,
+		}
+		if caseResult.Result.Output != "" {
+			suite.SystemOut = &junitOutput{Text: caseResult.Result.Output}
+		}
+
+		for _, check := range caseResult.Result.Checks {
+			testCase := junitTestCase{
+				Name:      check.Name,
+				ClassName: caseResult.Case.Name,
+			}
+			if !check.Passed {
+				testCase.Failure = &junitFailure{
+					Message:  checkMessage(check),
+					Expected: fmt.Sprint(check.Expected),
+					Got:      fmt.Sprint(check.Got),
+				}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		suite.Tests = len(suite.TestCases)
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+	return report
+}
+
+// Writes the JUnit XML report of robin tests
+func WriteJUnitReport(filename string, results []CaseResult) error {
+	byt, err := xml.MarshalIndent(newJUnitReport(results), "", "  ")
+	if err == nil {
+		content := append([]byte(xml.Header), byt...)
+		err = ioutil.WriteFile(filename, append(content, '\n'), 0644)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":         err,
+			"report file": filename,
+		}).Error("Cannot write JUnit report")
+		return fmt.Errorf("Cannot write JUnit report")
+	}
+	return nil
+}
+
+// Indents each line of a text, to put it in a TAP YAML block
+func indentLines(text, indent string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return indent + strings.Join(lines, "\n"+indent)
+}
+
+// Returns the TAP report of robin tests: one test point per expectation
+// check. The output of robin is given in the diagnostics of failed checks.
+func TAPReport(results []CaseResult) string {
+	var builder strings.Builder
+	nbChecks := 0
+	for _, caseResult := range results {
+		nbChecks += len(caseResult.Result.Checks)
+	}
+
+	builder.WriteString("TAP version 13\n")
+	builder.WriteString(fmt.Sprintf("1..%d\n", nbChecks))
+
+	number := 0
+	for _, caseResult := range results {
+		for _, check := range caseResult.Result.Checks {
+			number++
+			status := "ok"
+			if !check.Passed {
+				status = "not ok"
+			}
+			builder.WriteString(fmt.Sprintf("%s %d - %s: %s\n", status, number,
+				caseResult.Case.Name, check.Name))
+
+			if !check.Passed {
+				builder.WriteString("  ---\n")
+				builder.WriteString(fmt.Sprintf("  message: %q\n",
+					checkMessage(check)))
+				builder.WriteString(fmt.Sprintf("  expected: %q\n",
+					fmt.Sprint(check.Expected)))
+				builder.WriteString(fmt.Sprintf("  got: %q\n",
+					fmt.Sprint(check.Got)))
+				if caseResult.Result.Output != "" {
+					builder.WriteString("  output: |\n")
+					builder.WriteString(indentLines(caseResult.Result.Output,
+						"    ") + "\n")
+				}
+				builder.WriteString("  ...\n")
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// Writes the reports requested on the command line
+func writeReports(arguments map[string]interface{},
+	results []CaseResult) error {
+	if arguments["--report-junit"] != nil {
+		err := WriteJUnitReport(arguments["--report-junit"].(string), results)
+		if err != nil {
+			return err
+		}
+	}
+
+	if arguments["--report-tap"] == true {
+		fmt.Print(TAPReport(results))
+	}
+	return nil
+}
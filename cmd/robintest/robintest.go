@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 )
 
 var (
@@ -27,6 +28,10 @@ const (
 
 func setupLogging(arguments map[string]interface{}) {
 	log.SetOutput(os.Stdout)
+	if arguments["--report-tap"] == true {
+		// stdout is used by the TAP report
+		log.SetOutput(os.Stderr)
+	}
 
 	customFormatter := new(log.TextFormatter)
 	customFormatter.TimestampFormat = "2006-01-02 15:04:05.000"
@@ -46,9 +51,12 @@ func main() {
 }
 
 func mainReturnWithCode() int {
-	usage := `Tests one robin execution.
+	usage := `Tests one robin execution, or a suite of robin executions.
 
 Usage: 
+  robintest suite <suite-file> [--parallel=<n>] [--robin=<binary>]
+  			[--ctx-scope=<scope>] [--cover=<file>]
+  			[--report-junit=<file>] [--report-tap] [--debug]
   robintest <description-file>
   			--test-timeout=<seconds>
   			[(--expect-robin-success | --expect-robin-failure |
@@ -60,11 +68,92 @@ Usage:
   			[(--expect-ctx-clean | --expect-ctx-busy)]
   			[(--expect-ctx-clean-at-begin | --expect-ctx-busy-at-begin)]
   			[(--expect-ctx-clean-at-end | --expect-ctx-busy-at-end)]
+  			[--ctx-scope=<scope>]
+  			[--expect-duration-below=<seconds>]
+  			[--expect-duration-above=<seconds>]
+  			[--expect-batsim-killed-by=<timeout>]
+  			[--expect-sched-killed-by=<timeout>]
+  			[--expect-export-file=<suffix>...]
+  			[--expect-csv-value=<spec>...]
+  			[--expect-json-value=<spec>...]
+  			[--expect-log-match=<spec>...]
+  			[--expect-log-no-match=<spec>...]
   			[--result-check-script=<file>]
+  			[--report-junit=<file>] [--report-tap]
+  			[--robin=<binary>]
   			[--cover=<file>]
   			[--debug]
   robintest -h | --help
-  robintest --version`
+  robintest --version
+
+Robin options:
+  --robin=<binary>  robin binary to test (e.g. a freshly built one).
+                    By default, robin is searched in the PATH.
+
+Context options:
+  --ctx-scope=<scope>  How the context cleanliness before and after robin's
+                       execution is computed [default: global].
+                       global: no Batsim nor batsched runs on the machine.
+                       run: no process of the tested run (tagged by an
+                       environment marker) runs, and its socket port is
+                       not in use. Reliable on shared machines and with
+                       parallel suite cases. Linux only.
+
+Timing options:
+  --expect-duration-below=<seconds>   Expect robin to run less than <seconds>.
+  --expect-duration-above=<seconds>   Expect robin to run more than <seconds>.
+  --expect-batsim-killed-by=<timeout> Expect robin to kill Batsim because of
+                                      <timeout>: simulation-timeout,
+                                      success-timeout, failure-timeout,
+                                      total-timeout, stall-timeout or none.
+  --expect-sched-killed-by=<timeout>  Same for the scheduler.
+
+Content options:
+  --expect-export-file=<suffix>  Expect Batsim to write <prefix><suffix>,
+                                 where <prefix> is Batsim's export prefix.
+  --expect-csv-value=<spec>      Expect a column of a Batsim CSV output to
+                                 have a value in all rows.
+                                 <spec> is <suffix>:<column>=<value>,
+                                 e.g. _schedule.csv:nb_jobs_finished=4.
+  --expect-json-value=<spec>     Expect a value in a Batsim JSON output.
+                                 <spec> is <suffix>:<key>=<value>, <key>
+                                 being a dot-separated path.
+  --expect-log-match=<spec>      Expect a process log to match a regex.
+                                 <spec> is <process>:<regex>, <process>
+                                 being Batsim or Scheduler.
+                                 Rotated log files are also read.
+  --expect-log-no-match=<spec>   Expect a process log not to match a regex.
+
+Report options:
+  --report-junit=<file>  Write a JUnit XML report in <file>, with one
+                         testcase per expectation check.
+  --report-tap           Print a TAP report on stdout, with one test point
+                         per expectation check. Logs are then printed on
+                         stderr.
+
+Suite options:
+  --parallel=<n>    Number of test cases run at the same time [default: 1].
+                    Context expectations are unreliable if several cases
+                    run at the same time, unless --ctx-scope=run is set.
+
+A suite file is a YAML file that lists test cases. Paths are relative to it.
+  cases:
+    - name: nosched-ok
+      description: batsim_nosched_ok.yaml
+      timeout: 30
+      expect:
+        robin: success          # success, failure or killed
+        batsim: success         # success, failure or killed
+        sched: absent           # success, failure, killed or absent
+        ctx: clean              # clean or busy (also ctx-at-begin/end)
+        duration-below: 10      # also duration-above
+        batsim-killed-by: none  # a timeout or none (also sched-killed-by)
+        export-files: [_jobs.csv, _schedule.csv]
+        csv-values: ["_schedule.csv:nb_jobs_finished=4"]
+        json-values: []
+        log-match: ["Batsim:Simulation is finished"]
+        log-no-match: ["Scheduler:Traceback"]
+      check-script: checkscript_success.bash`
 
 	robintestVersion := version
 	if robintestVersion == "" {
@@ -92,6 +181,10 @@ Usage:
 
 	setupLogging(arguments)
 
+	if arguments["suite"] == true {
+		return suiteMain(arguments)
+	}
+
 	// Has robin been successful? (returned 0 before test timeout)
 	robinExpectation := EXPECT_NOTHING
 	if arguments["--expect-robin-success"] == true {
@@ -157,6 +250,35 @@ Usage:
 		return 1
 	}
 
+	// How long did robin run?
+	durationBelow, err := parseExpectedDuration(arguments,
+		"--expect-duration-below")
+	if err != nil {
+		return 1
+	}
+	durationAbove, err := parseExpectedDuration(arguments,
+		"--expect-duration-above")
+	if err != nil {
+		return 1
+	}
+
+	ctxScope, err := parseCtxScope(arguments)
+	if err != nil {
+		return 1
+	}
+
+	// Which timeout made robin kill Batsim or the scheduler?
+	batsimKilledBy, err := parseExpectedKillingTimeout(arguments,
+		"--expect-batsim-killed-by")
+	if err != nil {
+		return 1
+	}
+	schedKilledBy, err := parseExpectedKillingTimeout(arguments,
+		"--expect-sched-killed-by")
+	if err != nil {
+		return 1
+	}
+
 	coverFile := ""
 	if arguments["--cover"] != nil {
 		coverFile = arguments["--cover"].(string)
@@ -167,205 +289,376 @@ Usage:
 		resultCheckScript = arguments["--result-check-script"].(string)
 	}
 
-	testResult := RobinTest(arguments["<description-file>"].(string),
-		coverFile, resultCheckScript, testTimeout,
-		robinExpectation, batsimExpectation, schedExpectation,
-		ctxExpectation, ctxExpectationAtBegin, ctxExpectationAtEnd)
+	expect := Expectations{
+		Robin:             robinExpectation,
+		Batsim:            batsimExpectation,
+		Sched:             schedExpectation,
+		Ctx:               ctxExpectation,
+		CtxAtBegin:        ctxExpectationAtBegin,
+		CtxAtEnd:          ctxExpectationAtEnd,
+		CtxScope:          ctxScope,
+		DurationBelow:     durationBelow,
+		DurationAbove:     durationAbove,
+		BatsimKilledBy:    batsimKilledBy,
+		SchedKilledBy:     schedKilledBy,
+		ExportFiles:       arguments["--expect-export-file"].([]string),
+		CSVValues:         arguments["--expect-csv-value"].([]string),
+		JSONValues:        arguments["--expect-json-value"].([]string),
+		LogMatches:        arguments["--expect-log-match"].([]string),
+		LogNoMatches:      arguments["--expect-log-no-match"].([]string),
+		ResultCheckScript: resultCheckScript,
+	}
+
+	// Content expectations are checked before running robin
+	if err := checkContentExpectations(expect); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Invalid content expectation")
+		return 1
+	}
+
+	descriptionFile := arguments["<description-file>"].(string)
+	runOpts := batexpe.RunRobinOptions{
+		Binary:    robinBinary(arguments),
+		CoverFile: coverFile,
+		Timeout:   testTimeout,
+	}
+	testResult := RobinTest(descriptionFile, runOpts, expect)
 
-	return testResult
+	err = writeReports(arguments, []CaseResult{{
+		Case:   SuiteCase{Name: descriptionFile, Description: descriptionFile},
+		Result: testResult,
+	}})
+	if err != nil || !testResult.Passed() {
+		return 1
+	}
+	return 0
 }
 
-func RobinTest(descriptionFile, coverFile, resultCheckScript string,
-	testTimeout float64,
-	robinExpectation, batsimExpectation, schedExpectation,
-	ctxExpectation, ctxExpectationAtBegin, ctxExpectationAtEnd int) int {
+// Expected outcome of one robin execution.
+// Each state is one of the EXPECT_* values.
+type Expectations struct {
+	Robin      int
+	Batsim     int
+	Sched      int
+	Ctx        int
+	CtxAtBegin int
+	CtxAtEnd   int
+	// How CtxAtBegin and CtxAtEnd are computed (one of ctxScopes)
+	CtxScope string
+
+	// Bounds of robin's duration in seconds (unchecked if not positive)
+	DurationBelow float64
+	DurationAbove float64
+
+	// Timeouts expected to kill the processes (unchecked if empty)
+	BatsimKilledBy string
+	SchedKilledBy  string
+
+	// Files expected in Batsim's export prefix (as suffixes of the prefix)
+	ExportFiles []string
+	// Values expected in Batsim's CSV and JSON outputs
+	// (<file-suffix>:<column-or-key>=<value>)
+	CSVValues  []string
+	JSONValues []string
+	// Regexes expected to match or not the process logs (<process>:<regex>)
+	LogMatches   []string
+	LogNoMatches []string
+
+	ResultCheckScript string
+}
 
-	// Computing whether the context is clean or not is done by checking whether
-	// any batsim or batsched is running. This is intentionally done with a
-	// different function (and technique) that the one done within robin.
+func (expect Expectations) hasContentExpectations() bool {
+	return len(expect.ExportFiles) > 0 || len(expect.CSVValues) > 0 ||
+		len(expect.JSONValues) > 0 || len(expect.LogMatches) > 0 ||
+		len(expect.LogNoMatches) > 0
+}
 
-	batRunningAtBegin, err1 := batexpe.IsBatsimOrBatschedRunning()
-	ctxCleanAtBegin := batRunningAtBegin == false
+// Timeouts that can make robin kill a process. none means that the process
+// is not killed by any timeout.
+var killingTimeouts = []string{"none", "simulation-timeout",
+	"success-timeout", "failure-timeout", "total-timeout", "stall-timeout"}
 
-	rresult := batexpe.RunRobin(descriptionFile, coverFile, testTimeout)
+func isKillingTimeout(name string) bool {
+	for _, timeout := range killingTimeouts {
+		if name == timeout {
+			return true
+		}
+	}
+	return false
+}
 
-	batRunningAtEnd, err2 := batexpe.IsBatsimOrBatschedRunning()
-	ctxCleanAtEnd := batRunningAtEnd == false
+// Scopes of the context cleanliness: any Batsim or batsched on the machine,
+// or the processes and the socket of the tested run
+var ctxScopes = []string{"global", "run"}
 
-	robintestReturnValue := 0
+// Reads the context scope from the command-line arguments
+func parseCtxScope(arguments map[string]interface{}) (string, error) {
+	scope := arguments["--ctx-scope"].(string)
+	for _, ctxScope := range ctxScopes {
+		if scope == ctxScope {
+			return scope, nil
+		}
+	}
 
-	jsonLines, parseRobinOutputErr := batexpe.ParseRobinOutput(rresult.Output)
+	log.WithFields(log.Fields{
+		"--ctx-scope": scope,
+		"expected":    strings.Join(ctxScopes, ", "),
+	}).Error("Invalid context scope")
+	return "", fmt.Errorf("Invalid context scope")
+}
 
-	if (err1 != nil) || (err2 != nil) {
-		robintestReturnValue = 1
+// Reads an optional duration expectation from the command-line arguments
+func parseExpectedDuration(arguments map[string]interface{},
+	option string) (float64, error) {
+	if arguments[option] == nil {
+		return 0, nil
 	}
 
-	// Robin result
-	if robinExpectation != EXPECT_NOTHING {
-		expectedRobinSuccess := robinExpectation == EXPECT_TRUE
-		expectedRobinKilled := robinExpectation == EXPECT_KILLED
-		robinSuccess := rresult.Succeeded
-		robinKilled := rresult.Finished == false
-
-		if robinSuccess != expectedRobinSuccess {
-			log.WithFields(log.Fields{
-				"expected": expectedRobinSuccess,
-				"got":      robinSuccess,
-			}).Error("Unexpected robin success state")
-
-			robintestReturnValue = 1
-		}
+	duration, err := strconv.ParseFloat(arguments[option].(string), 64)
+	if err != nil || duration <= 0 {
+		log.WithFields(log.Fields{
+			option: arguments[option].(string),
+		}).Error("Invalid duration expectation " +
+			"(expected a positive number of seconds)")
+		return 0, fmt.Errorf("Invalid duration expectation")
+	}
+	return duration, nil
+}
 
-		if robinKilled != expectedRobinKilled {
-			log.WithFields(log.Fields{
-				"expected": expectedRobinKilled,
-				"got":      robinKilled,
-			}).Error("Unexpected robin kill state")
+// Reads an optional killing timeout expectation from the command-line
+// arguments
+func parseExpectedKillingTimeout(arguments map[string]interface{},
+	option string) (string, error) {
+	if arguments[option] == nil {
+		return "", nil
+	}
 
-			robintestReturnValue = 1
-		}
+	timeout := arguments[option].(string)
+	if !isKillingTimeout(timeout) {
+		log.WithFields(log.Fields{
+			option:     timeout,
+			"expected": strings.Join(killingTimeouts, ", "),
+		}).Error("Invalid killing timeout expectation")
+		return "", fmt.Errorf("Invalid killing timeout expectation")
 	}
+	return timeout, nil
+}
 
-	// Batsim successfulness
-	if batsimExpectation != EXPECT_NOTHING {
-		expectedBatsimSuccess := batsimExpectation == EXPECT_TRUE
-		expectedBatsimKilled := batsimExpectation == EXPECT_KILLED
-		batsimSuccess, batsimKilled := batexpe.WasBatsimSuccessful(jsonLines)
-
-		if batsimSuccess != expectedBatsimSuccess {
-			log.WithFields(log.Fields{
-				"expected": expectedBatsimSuccess,
-				"got":      batsimSuccess,
-			}).Error("Unexpected batsim success state")
-
-			robintestReturnValue = 1
-		}
+// Outcome of one expectation check
+type CheckResult struct {
+	Name     string
+	Expected interface{}
+	Got      interface{}
+	Passed   bool
+}
 
-		if batsimKilled != expectedBatsimKilled {
-			log.WithFields(log.Fields{
-				"expected": expectedBatsimKilled,
-				"got":      batsimKilled,
-			}).Error("Unexpected batsim kill state")
+// Outcome of one robin test
+type TestResult struct {
+	Checks   []CheckResult
+	Duration float64
+	// What robin printed
+	Output string
+}
 
-			robintestReturnValue = 1
+// Returns whether all the checks of a test passed
+func (result TestResult) Passed() bool {
+	return len(result.FailedChecks()) == 0
+}
+
+func (result TestResult) FailedChecks() []CheckResult {
+	var failed []CheckResult
+	for _, check := range result.Checks {
+		if !check.Passed {
+			failed = append(failed, check)
 		}
 	}
+	return failed
+}
 
-	// Sched successfulness and presence
-	if schedExpectation != EXPECT_NOTHING {
-		expectedSchedSuccess := schedExpectation == EXPECT_TRUE
-		expectedSchedPresence := schedExpectation != EXPECT_ABSENCE
-		expectedSchedKilled := schedExpectation == EXPECT_KILLED
-		schedSuccess, schedPresence, schedKilled := batexpe.WasSchedSuccessful(jsonLines)
-
-		if schedSuccess != expectedSchedSuccess {
-			log.WithFields(log.Fields{
-				"expected": expectedSchedSuccess,
-				"got":      schedSuccess,
-			}).Error("Unexpected sched success state")
-
-			robintestReturnValue = 1
-		}
+// Compares an observed value to its expected value
+func (result *TestResult) check(name string, expected, got interface{}) {
+	result.checkPassed(name, expected, got, expected == got)
+}
+
+// Records a check whose success is computed by the caller
+func (result *TestResult) checkPassed(name string, expected, got interface{},
+	passed bool) {
+	result.Checks = append(result.Checks, CheckResult{
+		Name:     name,
+		Expected: expected,
+		Got:      got,
+		Passed:   passed,
+	})
+
+	if !passed {
+		log.WithFields(log.Fields{
+			"expected": expected,
+			"got":      got,
+		}).Error("Unexpected " + name)
+	}
+}
 
-		if schedPresence != expectedSchedPresence {
-			log.WithFields(log.Fields{
-				"expected": expectedSchedPresence,
-				"got":      schedPresence,
-			}).Error("Unexpected sched presence state")
+// Records an error that prevented a check from being done
+func (result *TestResult) fail(name string, err error) {
+	result.Checks = append(result.Checks, CheckResult{
+		Name:     name,
+		Expected: "no error",
+		Got:      err.Error(),
+		Passed:   false,
+	})
+}
 
-			robintestReturnValue = 1
-		}
+// Returns the robin binary set on the command line (empty if unset)
+func robinBinary(arguments map[string]interface{}) string {
+	if arguments["--robin"] == nil {
+		return ""
+	}
+	return arguments["--robin"].(string)
+}
 
-		if schedKilled != expectedSchedKilled {
-			log.WithFields(log.Fields{
-				"expected": expectedSchedKilled,
-				"got":      schedKilled,
-			}).Error("Unexpected sched kill state")
+func RobinTest(descriptionFile string, runOpts batexpe.RunRobinOptions,
+	expect Expectations) TestResult {
+	testTimeout := runOpts.Timeout
+	var result TestResult
+	start := time.Now()
 
-			robintestReturnValue = 1
+	// Computing whether the context is clean or not is done by checking whether
+	// any batsim or batsched is running. This is intentionally done with a
+	// different function (and technique) that the one done within robin.
+	// In the run scope, only the processes and the socket of this run are
+	// considered: the processes are tagged by a marker in robin's environment.
+	isContextBusy := batexpe.IsBatsimOrBatschedRunning
+	if expect.CtxScope == "run" {
+		scope := runScope(descriptionFile)
+		runOpts.Env = append(append([]string{}, runOpts.Env...),
+			scope.MarkerEnv())
+		isContextBusy = func() (bool, error) {
+			return batexpe.IsRunScopeBusy(scope)
 		}
 	}
 
-	// Context cleanliness during robin's execution
-	if ctxExpectation != EXPECT_NOTHING {
-		expectedContextClean := ctxExpectation == EXPECT_TRUE
-		contextClean := batexpe.WasContextClean(jsonLines)
+	batRunningAtBegin, err1 := isContextBusy()
+	ctxCleanAtBegin := batRunningAtBegin == false
 
-		if contextClean != expectedContextClean {
-			log.WithFields(log.Fields{
-				"expected": expectedContextClean,
-				"got":      contextClean,
-			}).Error("Unexpected context cleanliness during robin's execution")
+	rresult := batexpe.RunRobinWithOptions(descriptionFile, runOpts)
+	result.Output = rresult.Output
 
-			robintestReturnValue = 1
-		}
+	batRunningAtEnd, err2 := isContextBusy()
+	ctxCleanAtEnd := batRunningAtEnd == false
+
+	events, parseRobinOutputErr := batexpe.ParseRobinEvents(rresult.Output)
+
+	if err1 != nil {
+		result.fail("context inspection before robin's execution", err1)
+	}
+	if err2 != nil {
+		result.fail("context inspection after robin's execution", err2)
 	}
 
-	// Context cleanliness before robin's execution
-	if ctxExpectationAtBegin != EXPECT_NOTHING {
-		expectedCtxCleanAtBegin := ctxExpectationAtBegin == EXPECT_TRUE
+	// Robin result
+	if expect.Robin != EXPECT_NOTHING {
+		result.check("robin success state", expect.Robin == EXPECT_TRUE,
+			rresult.Succeeded)
+		result.check("robin kill state", expect.Robin == EXPECT_KILLED,
+			rresult.Finished == false)
+	}
 
-		if ctxCleanAtBegin != expectedCtxCleanAtBegin {
-			log.WithFields(log.Fields{
-				"expected": expectedCtxCleanAtBegin,
-				"got":      ctxCleanAtBegin,
-			}).Error("Unexpected context cleanliness before robin's execution")
+	// Batsim successfulness
+	if expect.Batsim != EXPECT_NOTHING {
+		batsimSuccess, batsimKilled := events.ProcessOutcome("Batsim")
+		result.check("batsim success state", expect.Batsim == EXPECT_TRUE,
+			batsimSuccess)
+		result.check("batsim kill state", expect.Batsim == EXPECT_KILLED,
+			batsimKilled)
+	}
 
-			robintestReturnValue = 1
-		}
+	// Sched successfulness and presence
+	if expect.Sched != EXPECT_NOTHING {
+		schedSuccess, schedKilled := events.ProcessOutcome("Scheduler")
+		result.check("sched success state", expect.Sched == EXPECT_TRUE,
+			schedSuccess)
+		result.check("sched presence state", expect.Sched != EXPECT_ABSENCE,
+			events.SchedPresent())
+		result.check("sched kill state", expect.Sched == EXPECT_KILLED,
+			schedKilled)
 	}
 
-	// Context cleanliness after robin's execution
-	if ctxExpectationAtEnd != EXPECT_NOTHING {
-		expectedCtxCleanAtEnd := ctxExpectationAtEnd == EXPECT_TRUE
+	// Context cleanliness during robin's execution
+	if expect.Ctx != EXPECT_NOTHING {
+		result.check("context cleanliness during robin's execution",
+			expect.Ctx == EXPECT_TRUE, events.ContextClean())
+	}
 
-		if ctxCleanAtEnd != expectedCtxCleanAtEnd {
-			log.WithFields(log.Fields{
-				"expected": expectedCtxCleanAtEnd,
-				"got":      ctxCleanAtEnd,
-			}).Error("Unexpected context cleanliness after robin's execution")
+	// Context cleanliness before robin's execution
+	if expect.CtxAtBegin != EXPECT_NOTHING {
+		result.check("context cleanliness before robin's execution",
+			expect.CtxAtBegin == EXPECT_TRUE, ctxCleanAtBegin)
+	}
 
-			robintestReturnValue = 1
-		}
+	// Context cleanliness after robin's execution
+	if expect.CtxAtEnd != EXPECT_NOTHING {
+		result.check("context cleanliness after robin's execution",
+			expect.CtxAtEnd == EXPECT_TRUE, ctxCleanAtEnd)
 	}
 
-	// Run check script if everything went as expected so far
-	if robintestReturnValue == 0 {
-		if resultCheckScript != "" {
-			// First, we need to retrieve Batsim output prefix.
-			// To do so, we can parse the batsim command defined in
-			// the robin description file.
-			byt, err := ioutil.ReadFile(descriptionFile)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"err":      err,
-					"filename": descriptionFile,
-				}).Error("Cannot open description file")
-				robintestReturnValue = 1
-			}
+	// Duration of robin's execution, as logged by robin.
+	// robin does not log it if it has been killed, in which case the duration
+	// of the robin process is used.
+	if expect.DurationBelow > 0 || expect.DurationAbove > 0 {
+		duration, found := events.Duration()
+		if !found {
+			duration = rresult.Duration
+		}
 
-			exp, err := batexpe.FromYaml(string(byt))
-			if err != nil {
-				robintestReturnValue = 1
-			}
+		if expect.DurationBelow > 0 {
+			result.checkPassed("robin duration (upper bound)",
+				fmt.Sprintf("below %g seconds", expect.DurationBelow),
+				duration, duration < expect.DurationBelow)
+		}
+		if expect.DurationAbove > 0 {
+			result.checkPassed("robin duration (lower bound)",
+				fmt.Sprintf("above %g seconds", expect.DurationAbove),
+				duration, duration > expect.DurationAbove)
+		}
+	}
 
-			batargs, err := batexpe.ParseBatsimCommand(exp.Batcmd)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"err": err,
-				}).Error("Cannot parse Batsim command")
-				robintestReturnValue = 1
-			}
+	// Timeouts that made robin kill the processes
+	if expect.BatsimKilledBy != "" {
+		result.check("batsim killing timeout", expect.BatsimKilledBy,
+			killingTimeoutName(events, "Batsim"))
+	}
+	if expect.SchedKilledBy != "" {
+		result.check("sched killing timeout", expect.SchedKilledBy,
+			killingTimeoutName(events, "Scheduler"))
+	}
 
-			checkScriptSuccessful, err := RunCheckScript(resultCheckScript,
-				exp.OutputDir, batargs.ExportPrefix, testTimeout)
-			if err != nil {
-				robintestReturnValue = 1
-			}
+	// Files produced by robin's execution
+	if expect.hasContentExpectations() {
+		run, err := readExecutedRun(descriptionFile)
+		if err != nil {
+			result.fail("description reading", err)
+		} else {
+			result.checkContent(run, expect)
+		}
+	}
 
-			if !checkScriptSuccessful {
-				robintestReturnValue = 1
-			}
+	// Run check script if everything went as expected so far
+	if result.Passed() && expect.ResultCheckScript != "" {
+		// The check script is called with Batsim's export prefix, which is
+		// retrieved from the description robin executed
+		run, err := readExecutedRun(descriptionFile)
+		checkScriptSuccessful := false
+		if err == nil {
+			checkScriptSuccessful, err = RunCheckScript(
+				expect.ResultCheckScript, run.Experiment.OutputDir,
+				run.Batargs.ExportPrefix, testTimeout)
+		}
+		if err != nil {
+			result.fail("result check script execution", err)
+		} else {
+			result.check("result check script success", true,
+				checkScriptSuccessful)
 		}
 	}
 
@@ -373,10 +666,99 @@ func RobinTest(descriptionFile, coverFile, resultCheckScript string,
 		log.WithFields(log.Fields{
 			"err": parseRobinOutputErr,
 		}).Error("Could not parse robin output")
-		robintestReturnValue = 1
+		result.fail("robin output parsing", parseRobinOutputErr)
+	}
+
+	result.Duration = time.Since(start).Seconds()
+	return result
+}
+
+// Returns which timeout made robin kill a process, or none
+func killingTimeoutName(events batexpe.RobinEvents,
+	processName string) string {
+	timeout := events.KillingTimeout(processName)
+	if timeout == "" {
+		return "none"
+	}
+	return timeout
+}
+
+// Returns the scope of a new run of a description file.
+// Its socket is unchecked if the description cannot be read.
+func runScope(descriptionFile string) batexpe.RunScope {
+	scope := batexpe.RunScope{Marker: batexpe.NewRunMarker()}
+	if _, batargs, err := readDescription(descriptionFile); err == nil {
+		scope.Socket = batargs.Socket
+	}
+	return scope
+}
+
+// Reads a description file and the Batsim arguments of its command
+func readDescription(descriptionFile string) (batexpe.Experiment,
+	batexpe.BatsimArgs, error) {
+	var batargs batexpe.BatsimArgs
+	byt, err := ioutil.ReadFile(descriptionFile)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":      err,
+			"filename": descriptionFile,
+		}).Error("Cannot open description file")
+		return batexpe.Experiment{}, batargs,
+			fmt.Errorf("Cannot open description file")
 	}
 
-	return robintestReturnValue
+	exp, err := batexpe.FromYaml(string(byt))
+	if err != nil {
+		return exp, batargs, err
+	}
+
+	batargs, err = batexpe.ParseBatsimCommand(exp.Batcmd)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot parse Batsim command")
+		return exp, batargs, fmt.Errorf("Cannot parse Batsim command")
+	}
+	return exp, batargs, nil
+}
+
+// What a robin execution actually used, as written by robin in its output
+// directory
+type executedRun struct {
+	// The effective description (e.g. with an injected export prefix)
+	Experiment batexpe.Experiment
+	Batargs    batexpe.BatsimArgs
+	// Log directory of the last attempt
+	LogDir string
+}
+
+// Reads what the last robin execution of a description file used.
+// robin writes the description it executed and the log directory of each
+// attempt in its output directory. The given description is used if robin
+// has not written them.
+func readExecutedRun(descriptionFile string) (executedRun, error) {
+	var run executedRun
+	exp, batargs, err := readDescription(descriptionFile)
+	if err != nil {
+		return run, err
+	}
+
+	effectiveFile := batexpe.DescriptionFilename(exp.OutputDir)
+	if _, err := os.Stat(effectiveFile); err == nil {
+		exp, batargs, err = readDescription(effectiveFile)
+		if err != nil {
+			return run, err
+		}
+	}
+
+	run.Experiment = exp
+	run.Batargs = batargs
+	run.LogDir = exp.LogDir()
+	if runResult, err := batexpe.ReadResult(exp.OutputDir); err == nil &&
+		len(runResult.Attempts) > 0 {
+		run.LogDir = runResult.Attempts[len(runResult.Attempts)-1].LogDir
+	}
+	return run, nil
 }
 
 func RunCheckScript(resultCheckScript, robinOutputDir, batsimExportPrefix string,
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	"github.com/Lucas-Doctorate-Project/batexpe/batexpetest"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Builds a program of the module in dir and returns its path
+func buildProgram(t *testing.T, dir, name string) string {
+	binary := filepath.Join(dir, name)
+	build := exec.Command("go", "build", "-o", binary, "../"+name)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Cannot build %s: %s\n%s", name, err, out)
+	}
+	return binary
+}
+
+// Builds robin and the fake Batsim, and returns their paths
+func buildRobinAndFakeBatsim(t *testing.T) (robin, fakeBatsim string) {
+	binDir := t.TempDir()
+	robin = buildProgram(t, binDir, "robin")
+	fakeBatsim, err := batexpetest.BuildFakeBatsim(binDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return robin, fakeBatsim
+}
+
+// Tests the expectations against robin executions on a fake Batsim
+func TestRobinTestExpectations(t *testing.T) {
+	// The test binary is also used as robintest (see TestRunMain)
+	if len(flag.Args()) > 0 {
+		t.Skip("Test binary used as robintest")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("The go tool is required to build robin")
+	}
+
+	robin, fakeBatsim := buildRobinAndFakeBatsim(t)
+
+	tests := []struct {
+		name          string
+		batsimOptions string
+		schedcmd      string
+		expect        Expectations
+		passed        bool
+	}{
+		{"nosched-ok", "--batexec", "", Expectations{
+			Robin:          EXPECT_TRUE,
+			Batsim:         EXPECT_TRUE,
+			Sched:          EXPECT_ABSENCE,
+			DurationBelow:  10,
+			BatsimKilledBy: "none",
+			ExportFiles:    []string{"_jobs.csv", "_schedule.csv"},
+			CSVValues:      []string{"_schedule.csv:nb_jobs_finished=4"},
+			LogMatches:     []string{"Batsim:Simulation is finished"},
+			LogNoMatches:   []string{"Batsim:Traceback"},
+		}, true},
+		{"nosched-ok-run-scope", "--batexec", "", Expectations{
+			Robin:      EXPECT_TRUE,
+			CtxAtBegin: EXPECT_TRUE,
+			CtxAtEnd:   EXPECT_TRUE,
+			CtxScope:   "run",
+		}, true},
+		{"sched-leak-run-scope", "",
+			"setsid sleep 1 </dev/null >/dev/null 2>&1 & sleep 0.2",
+			Expectations{
+				Robin:    EXPECT_TRUE,
+				CtxAtEnd: EXPECT_FALSE,
+				CtxScope: "run",
+			}, true},
+		{"nosched-ok-unexpected", "--batexec", "", Expectations{
+			Robin: EXPECT_FALSE,
+		}, false},
+		{"nosched-timeout", "--batexec --fake-hang", "", Expectations{
+			Robin:          EXPECT_FALSE,
+			Batsim:         EXPECT_KILLED,
+			BatsimKilledBy: "simulation-timeout",
+			DurationAbove:  1,
+		}, true},
+		{"nosched-crash", "--batexec --fake-crash=SEGV", "", Expectations{
+			Robin:       EXPECT_FALSE,
+			Batsim:      EXPECT_FALSE,
+			ExportFiles: []string{"_jobs.csv"},
+		}, false},
+		{"sched-ok", "", "sleep 0.2", Expectations{
+			Robin:  EXPECT_TRUE,
+			Batsim: EXPECT_TRUE,
+			Sched:  EXPECT_TRUE,
+		}, true},
+		{"sched-failure", "--fake-duration=10", "false", Expectations{
+			Robin:          EXPECT_FALSE,
+			Batsim:         EXPECT_KILLED,
+			Sched:          EXPECT_FALSE,
+			BatsimKilledBy: "failure-timeout",
+			SchedKilledBy:  "none",
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			description := fmt.Sprintf(`batcmd: %s -e %s/out -s %s %s
+output-dir: %s
+schedcmd: "%s"
+simulation-timeout: 2
+ready-timeout: 2
+success-timeout: 2
+failure-timeout: 0
+`, fakeBatsim, outputDir, batexpetest.FreeSocket(t), test.batsimOptions,
+				outputDir, test.schedcmd)
+			// Robin writes its own copy of the description in outputDir
+			descriptionFile := filepath.Join(t.TempDir(), "description.yaml")
+			err := ioutil.WriteFile(descriptionFile, []byte(description), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := RobinTest(descriptionFile, batexpe.RunRobinOptions{
+				Binary:  robin,
+				Timeout: 20,
+			}, test.expect)
+
+			if result.Passed() != test.passed {
+				t.Errorf("Unexpected test result: expected passed=%t, "+
+					"failed checks=%+v\nrobin output:\n%s", test.passed,
+					result.FailedChecks(), result.Output)
+			}
+		})
+	}
+}
+
+// Tests the content expectations on a run whose export prefix is injected by
+// robin, and whose first attempt fails as its socket is in use
+func TestRobinTestContentOfExecutedRun(t *testing.T) {
+	if len(flag.Args()) > 0 {
+		t.Skip("Test binary used as robintest")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("The go tool is required to build robin")
+	}
+
+	robin, fakeBatsim := buildRobinAndFakeBatsim(t)
+
+	outputDir := t.TempDir()
+	socket := batexpetest.FreeSocket(t)
+	description := fmt.Sprintf(`batcmd: %s -s %s
+output-dir: %s
+schedcmd: "sleep 0.2"
+simulation-timeout: 5
+ready-timeout: 1
+success-timeout: 2
+failure-timeout: 0
+export-prefix-policy: inject
+retries: 3
+`, fakeBatsim, socket, outputDir)
+	descriptionFile := filepath.Join(t.TempDir(), "description.yaml")
+	err := ioutil.WriteFile(descriptionFile, []byte(description), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port, _ := batexpe.PortFromBatSock(socket)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(1500*time.Millisecond, func() { listener.Close() })
+
+	result := RobinTest(descriptionFile, batexpe.RunRobinOptions{
+		Binary:  robin,
+		Timeout: 20,
+	}, Expectations{
+		Robin:        EXPECT_TRUE,
+		Batsim:       EXPECT_TRUE,
+		Sched:        EXPECT_TRUE,
+		Ctx:          EXPECT_TRUE,
+		ExportFiles:  []string{"_jobs.csv", "_schedule.csv"},
+		CSVValues:    []string{"_schedule.csv:nb_jobs_finished=4"},
+		LogMatches:   []string{"Batsim:Simulation is finished"},
+		LogNoMatches: []string{"Batsim:Traceback"},
+	})
+
+	if !result.Passed() {
+		t.Errorf("Unexpected test failure: failed checks=%+v\n"+
+			"robin output:\n%s", result.FailedChecks(), result.Output)
+	}
+	if _, err := os.Stat(outputDir + "/log/attempt-2"); err != nil {
+		t.Errorf("The first attempt did not fail: %s", err)
+	}
+}
+
+// Tests that the log expectations read the rotated and compressed log files
+func TestReadProcessLogsRotated(t *testing.T) {
+	logDir := t.TempDir()
+	files := map[string]string{
+		"batsim.log.3":  "first\n",
+		"batsim.log.2":  "second\n",
+		"batsim.log.1":  "third\n",
+		"batsim.log":    "last\n",
+		"sched.out.log": "out\n",
+		"sched.err.log": "err\n",
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(logDir, name), []byte(content),
+			0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := readProcessLogs(logDir, "Batsim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "first\nsecond\nthird\nlast\n" {
+		t.Errorf("Unexpected Batsim logs: %q", content)
+	}
+
+	// Compressed as with log-compress
+	for _, name := range []string{"batsim.log.2", "batsim.log"} {
+		filename := filepath.Join(logDir, name)
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		writer.Write([]byte(files[name]))
+		writer.Close()
+		err := ioutil.WriteFile(filename+".gz", compressed.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(filename)
+	}
+
+	content, err = readProcessLogs(logDir, "Batsim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "first\nsecond\nthird\nlast\n" {
+		t.Errorf("Unexpected compressed Batsim logs: %q", content)
+	}
+
+	content, err = readProcessLogs(logDir, "Scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "out\nerr\n" {
+		t.Errorf("Unexpected scheduler logs: %q", content)
+	}
+}
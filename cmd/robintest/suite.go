@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// One test case of a suite file
+type SuiteCase struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Timeout     float64                `json:"timeout"`
+	Expect      map[string]interface{} `json:"expect,omitempty"`
+	CheckScript string                 `json:"check-script,omitempty"`
+}
+
+// Test cases read from a suite file
+type Suite struct {
+	Cases []SuiteCase `json:"cases"`
+}
+
+// Outcome of one test case of a suite
+type CaseResult struct {
+	Case   SuiteCase
+	Result TestResult
+}
+
+// Values allowed for each expectation of a suite case
+var suiteExpectationValues = map[string]map[string]int{
+	"robin": {
+		"success": EXPECT_TRUE,
+		"failure": EXPECT_FALSE,
+		"killed":  EXPECT_KILLED,
+	},
+	"batsim": {
+		"success": EXPECT_TRUE,
+		"failure": EXPECT_FALSE,
+		"killed":  EXPECT_KILLED,
+	},
+	"sched": {
+		"success": EXPECT_TRUE,
+		"failure": EXPECT_FALSE,
+		"killed":  EXPECT_KILLED,
+		"absent":  EXPECT_ABSENCE,
+	},
+	"ctx": {
+		"clean": EXPECT_TRUE,
+		"busy":  EXPECT_FALSE,
+	},
+	"ctx-at-begin": {
+		"clean": EXPECT_TRUE,
+		"busy":  EXPECT_FALSE,
+	},
+	"ctx-at-end": {
+		"clean": EXPECT_TRUE,
+		"busy":  EXPECT_FALSE,
+	},
+}
+
+// Returns the expectations of a suite case
+func (suiteCase SuiteCase) expectations() (Expectations, error) {
+	values := make(map[string]int)
+	durations := make(map[string]float64)
+	killingTimeouts := make(map[string]string)
+	contents := make(map[string][]string)
+	for key, value := range suiteCase.Expect {
+		invalidValue := fmt.Errorf("Invalid value '%v' for expectation '%s'",
+			value, key)
+
+		switch key {
+		case "duration-below", "duration-above":
+			seconds, isNumber := value.(float64)
+			if !isNumber || seconds <= 0 {
+				return Expectations{}, invalidValue
+			}
+			durations[key] = seconds
+		case "batsim-killed-by", "sched-killed-by":
+			timeout, isString := value.(string)
+			if !isString || !isKillingTimeout(timeout) {
+				return Expectations{}, invalidValue
+			}
+			killingTimeouts[key] = timeout
+		case "export-files", "csv-values", "json-values", "log-match",
+			"log-no-match":
+			items, isList := value.([]interface{})
+			if !isList {
+				return Expectations{}, invalidValue
+			}
+			for _, item := range items {
+				itemString, isString := item.(string)
+				if !isString {
+					return Expectations{}, invalidValue
+				}
+				contents[key] = append(contents[key], itemString)
+			}
+		default:
+			allowed, keyExists := suiteExpectationValues[key]
+			if !keyExists {
+				return Expectations{}, fmt.Errorf("Unknown expectation '%s'",
+					key)
+			}
+
+			state, isString := value.(string)
+			expectation, valueExists := allowed[state]
+			if !isString || !valueExists {
+				return Expectations{}, invalidValue
+			}
+			values[key] = expectation
+		}
+	}
+
+	expect := Expectations{
+		Robin:             values["robin"],
+		Batsim:            values["batsim"],
+		Sched:             values["sched"],
+		Ctx:               values["ctx"],
+		CtxAtBegin:        values["ctx-at-begin"],
+		CtxAtEnd:          values["ctx-at-end"],
+		DurationBelow:     durations["duration-below"],
+		DurationAbove:     durations["duration-above"],
+		BatsimKilledBy:    killingTimeouts["batsim-killed-by"],
+		SchedKilledBy:     killingTimeouts["sched-killed-by"],
+		ExportFiles:       contents["export-files"],
+		CSVValues:         contents["csv-values"],
+		JSONValues:        contents["json-values"],
+		LogMatches:        contents["log-match"],
+		LogNoMatches:      contents["log-no-match"],
+		ResultCheckScript: suiteCase.CheckScript,
+	}
+	return expect, checkContentExpectations(expect)
+}
+
+// Makes a path relative to the directory of the suite file.
+// The result always contains a slash, so that scripts are not searched in
+// the PATH.
+func resolveSuitePath(suiteDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	resolved := filepath.Join(suiteDir, path)
+	if !strings.Contains(resolved, "/") {
+		resolved = "./" + resolved
+	}
+	return resolved
+}
+
+// Reads and checks a suite file.
+// The files referenced by the cases are relative to the suite file.
+func ReadSuite(filename string) (Suite, error) {
+	var suite Suite
+
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":        err,
+			"suite file": filename,
+		}).Error("Cannot open suite file")
+		return suite, fmt.Errorf("Cannot open suite file")
+	}
+
+	if err := yaml.Unmarshal(byt, &suite); err != nil {
+		log.WithFields(log.Fields{
+			"err":        err,
+			"suite file": filename,
+		}).Error("Cannot read suite file")
+		return suite, fmt.Errorf("Cannot read suite file")
+	}
+
+	if len(suite.Cases) == 0 {
+		log.WithFields(log.Fields{
+			"suite file": filename,
+		}).Error("Suite file has no test case")
+		return suite, fmt.Errorf("Suite file has no test case")
+	}
+
+	suiteDir := filepath.Dir(filename)
+	names := make(map[string]bool)
+	for i := range suite.Cases {
+		suiteCase := &suite.Cases[i]
+		if suiteCase.Name == "" {
+			suiteCase.Name = suiteCase.Description
+		}
+
+		var caseErr error
+		if suiteCase.Description == "" {
+			caseErr = fmt.Errorf("Test case has no description file")
+		} else if suiteCase.Timeout <= 0 {
+			caseErr = fmt.Errorf("Invalid test case timeout " +
+				"(expected a positive number of seconds)")
+		} else if names[suiteCase.Name] {
+			caseErr = fmt.Errorf("Duplicate test case name")
+		} else {
+			_, caseErr = suiteCase.expectations()
+		}
+
+		if caseErr != nil {
+			log.WithFields(log.Fields{
+				"err":        caseErr,
+				"suite file": filename,
+				"test case":  i,
+				"name":       suiteCase.Name,
+			}).Error("Invalid test case")
+			return suite, fmt.Errorf("Invalid test case")
+		}
+		names[suiteCase.Name] = true
+
+		suiteCase.Description = resolveSuitePath(suiteDir,
+			suiteCase.Description)
+		suiteCase.CheckScript = resolveSuitePath(suiteDir,
+			suiteCase.CheckScript)
+	}
+
+	return suite, nil
+}
+
+// Returns why a test failed, in a human-readable way
+func failureReasons(result TestResult) string {
+	var reasons []string
+	for _, check := range result.FailedChecks() {
+		reasons = append(reasons, fmt.Sprintf("%s (expected %v, got %v)",
+			check.Name, check.Expected, check.Got))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Runs the cases of a suite, at most parallel at the same time.
+// If runOpts.CoverFile is set, each case writes robin's coverage in
+// <CoverFile>.<case index>. The timeout of runOpts is replaced by the one of
+// each case. The context cleanliness is computed in ctxScope.
+func RunSuite(suite Suite, runOpts batexpe.RunRobinOptions, ctxScope string,
+	parallel int) []CaseResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]CaseResult, len(suite.Cases))
+	slots := make(chan bool, parallel)
+	var wg sync.WaitGroup
+
+	for i, suiteCase := range suite.Cases {
+		wg.Add(1)
+		slots <- true
+		go func(i int, suiteCase SuiteCase) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			caseRunOpts := runOpts
+			caseRunOpts.Timeout = suiteCase.Timeout
+			if runOpts.CoverFile != "" {
+				caseRunOpts.CoverFile = runOpts.CoverFile + "." + strconv.Itoa(i)
+			}
+
+			log.WithFields(log.Fields{
+				"test case":        suiteCase.Name,
+				"description file": suiteCase.Description,
+			}).Debug("Running test case")
+
+			// Expectations have been checked while reading the suite
+			expect, _ := suiteCase.expectations()
+			expect.CtxScope = ctxScope
+			result := RobinTest(suiteCase.Description, caseRunOpts, expect)
+			results[i] = CaseResult{Case: suiteCase, Result: result}
+
+			if result.Passed() {
+				log.WithFields(log.Fields{
+					"test case": suiteCase.Name,
+					"duration":  result.Duration,
+				}).Info("Test case passed")
+			} else {
+				log.WithFields(log.Fields{
+					"test case": suiteCase.Name,
+					"duration":  result.Duration,
+					"reasons":   failureReasons(result),
+				}).Error("Test case failed")
+			}
+		}(i, suiteCase)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// Runs a suite file. Returns 0 if all its cases passed, 1 otherwise.
+func suiteMain(arguments map[string]interface{}) int {
+	parallel := 1
+	if arguments["--parallel"] != nil {
+		value, err := strconv.Atoi(arguments["--parallel"].(string))
+		if err != nil || value < 1 {
+			log.WithFields(log.Fields{
+				"--parallel": arguments["--parallel"].(string),
+			}).Error("Invalid parallelism (expected a positive integer)")
+			return 1
+		}
+		parallel = value
+	}
+
+	coverFile := ""
+	if arguments["--cover"] != nil {
+		coverFile = arguments["--cover"].(string)
+	}
+
+	ctxScope, err := parseCtxScope(arguments)
+	if err != nil {
+		return 1
+	}
+
+	suite, err := ReadSuite(arguments["<suite-file>"].(string))
+	if err != nil {
+		return 1
+	}
+
+	runOpts := batexpe.RunRobinOptions{
+		Binary:    robinBinary(arguments),
+		CoverFile: coverFile,
+	}
+	results := RunSuite(suite, runOpts, ctxScope, parallel)
+	reportErr := writeReports(arguments, results)
+
+	var failed []string
+	for _, caseResult := range results {
+		if !caseResult.Result.Passed() {
+			failed = append(failed, caseResult.Case.Name)
+		}
+	}
+
+	fields := log.Fields{
+		"passed": len(results) - len(failed),
+		"failed": len(failed),
+	}
+	if len(failed) > 0 {
+		fields["failed cases"] = strings.Join(failed, ", ")
+		log.WithFields(fields).Error("Test suite failed")
+		return 1
+	}
+
+	log.WithFields(fields).Info("Test suite passed")
+	if reportErr != nil {
+		return 1
+	}
+	return 0
+}
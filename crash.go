@@ -0,0 +1,430 @@
+package batexpe
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Information about a process killed by a signal that may dump a core.
+// Robin starts each command in a bash wrapper: the crashed process may be
+// the wrapper itself or one of its subprocesses. PID and Executable are only
+// known once the core file of the crashed process has been found.
+type CrashInfo struct {
+	Signal        string `json:"signal"`
+	PID           int    `json:"pid,omitempty"`
+	Executable    string `json:"executable,omitempty"`
+	WrapperPID    int    `json:"wrapper-pid"`
+	CoreFile      string `json:"core-file,omitempty"`
+	BacktraceFile string `json:"backtrace-file,omitempty"`
+}
+
+// The process that dumped a core, as written in the core file
+type coreProcess struct {
+	PID  int
+	PGID int
+	// Name of the executable, truncated to 15 characters by the kernel
+	Executable string
+}
+
+// Signals whose default action is to terminate the process and dump a core
+var coreDumpSignals = map[syscall.Signal]bool{
+	syscall.SIGABRT: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGILL:  true,
+	syscall.SIGQUIT: true,
+	syscall.SIGSEGV: true,
+	syscall.SIGSYS:  true,
+	syscall.SIGTRAP: true,
+	syscall.SIGXCPU: true,
+	syscall.SIGXFSZ: true,
+}
+
+const corePatternFile = "/proc/sys/kernel/core_pattern"
+
+// Maximum time spent to generate one backtrace
+const backtraceTimeout = 60 * time.Second
+
+func CrashDir(outputDir string) string {
+	return outputDir + "/crash"
+}
+
+// Returns the crash information of a finished process,
+// or nil if the process has not been killed by a core-dumping signal.
+func newCrashInfo(msg CmdFinishedMsg) *CrashInfo {
+	if msg.Err == nil {
+		return nil
+	}
+
+	_, signal := ExitStatus(msg.Err)
+	if !coreDumpSignals[signal] {
+		return nil
+	}
+
+	return &CrashInfo{
+		Signal:     signal.String(),
+		WrapperPID: msg.PID,
+	}
+}
+
+// Raises the core file size soft limit to its hard limit.
+// Subprocesses inherit this limit.
+// The returned function restores the previous limit.
+func enableCoreDumps() (restore func()) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Warning("Cannot read core file size limit")
+		return func() {}
+	}
+
+	if limit.Max == 0 {
+		log.Warning("Core dumps are forbidden (hard core file size limit " +
+			"is 0)")
+	}
+
+	previous := limit
+	limit.Cur = limit.Max
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Warning("Cannot raise core file size limit")
+		return func() {}
+	}
+
+	return func() {
+		syscall.Setrlimit(syscall.RLIMIT_CORE, &previous)
+	}
+}
+
+// Converts a kernel core pattern to a glob pattern.
+// Format specifiers (%p, %e...) can match anything.
+func corePatternToGlob(pattern string) string {
+	r := regexp.MustCompile(`%.`)
+	return r.ReplaceAllStringFunc(pattern, func(specifier string) string {
+		if specifier == "%%" {
+			return "%"
+		}
+		return "*"
+	})
+}
+
+// Types of the ELF notes that describe the process in a core file
+const (
+	ntPrstatus = 1
+	ntPrpsinfo = 3
+)
+
+// Reads which process dumped a core file, from its ELF notes.
+// The process information of 64-bit cores comes from NT_PRPSINFO.
+// The layout of this note varies between 32-bit architectures, so only the
+// crashing thread information (NT_PRSTATUS) is used for 32-bit cores.
+func readCoreProcess(coreFile string) (coreProcess, error) {
+	var process coreProcess
+
+	file, err := elf.Open(coreFile)
+	if err != nil {
+		return process, err
+	}
+	defer file.Close()
+	if file.Type != elf.ET_CORE {
+		return process, fmt.Errorf("'%s' is not a core file", coreFile)
+	}
+
+	for _, prog := range file.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+		notes, err := ioutil.ReadAll(prog.Open())
+		if err != nil {
+			return process, err
+		}
+
+		for len(notes) >= 12 {
+			nameSize := int(file.ByteOrder.Uint32(notes[0:4]))
+			descSize := int(file.ByteOrder.Uint32(notes[4:8]))
+			noteType := file.ByteOrder.Uint32(notes[8:12])
+			descStart := 12 + (nameSize+3)/4*4
+			descEnd := descStart + descSize
+			if descEnd > len(notes) {
+				break
+			}
+			desc := notes[descStart:descEnd]
+			if next := descStart + (descSize+3)/4*4; next < len(notes) {
+				notes = notes[next:]
+			} else {
+				notes = nil
+			}
+
+			if file.Class == elf.ELFCLASS64 && noteType == ntPrpsinfo &&
+				len(desc) >= 56 {
+				process.PID = int(int32(file.ByteOrder.Uint32(desc[24:28])))
+				process.PGID = int(int32(file.ByteOrder.Uint32(desc[32:36])))
+				process.Executable = string(bytes.TrimRight(desc[40:56],
+					"\x00"))
+				return process, nil
+			}
+			if file.Class == elf.ELFCLASS32 && noteType == ntPrstatus &&
+				len(desc) >= 36 {
+				process.PID = int(int32(file.ByteOrder.Uint32(desc[24:28])))
+				process.PGID = int(int32(file.ByteOrder.Uint32(desc[32:36])))
+				return process, nil
+			}
+		}
+	}
+
+	return process, fmt.Errorf("No process information in '%s'", coreFile)
+}
+
+// Finds the most recent core file written by the kernel since a given time
+// by a process of a process group.
+// Robin starts each command in its own process group, whose ID is the PID
+// of the command wrapper: the core files of other processes are ignored.
+// Relative core patterns are relative to the working directory of the
+// crashed process, which is robin's one.
+func findCoreFile(since time.Time, pgid int) (string, coreProcess, error) {
+	byt, err := ioutil.ReadFile(corePatternFile)
+	if err != nil {
+		return "", coreProcess{}, fmt.Errorf("Cannot read core pattern: %s",
+			err)
+	}
+
+	pattern := strings.TrimSpace(string(byt))
+	if strings.HasPrefix(pattern, "|") {
+		return "", coreProcess{}, fmt.Errorf("Core dumps are piped to a "+
+			"program ('%s')", pattern)
+	}
+
+	glob := corePatternToGlob(pattern)
+	// Without %p, the kernel may append the PID (kernel.core_uses_pid)
+	candidates, _ := filepath.Glob(glob)
+	suffixed, _ := filepath.Glob(glob + ".*")
+	candidates = append(candidates, suffixed...)
+
+	coreFile := ""
+	var core coreProcess
+	var coreTime time.Time
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() || info.ModTime().Before(since) {
+			continue
+		}
+		if coreFile != "" && !info.ModTime().After(coreTime) {
+			continue
+		}
+
+		process, err := readCoreProcess(candidate)
+		if err != nil || process.PGID != pgid {
+			continue
+		}
+		coreFile = candidate
+		core = process
+		coreTime = info.ModTime()
+	}
+
+	if coreFile == "" {
+		return "", core, fmt.Errorf("No core file matching '%s' found for "+
+			"process group %d", pattern, pgid)
+	}
+	return coreFile, core, nil
+}
+
+// Retrieves the last core dump of a process group from systemd-coredump
+func dumpCoreWithCoredumpctl(since time.Time, pgid int,
+	destination string) (coreProcess, error) {
+	if _, err := exec.LookPath("coredumpctl"); err != nil {
+		return coreProcess{}, fmt.Errorf("Core dumps are piped to a " +
+			"program and coredumpctl is not available")
+	}
+
+	// systemd-coredump does not store process groups: the PIDs of the
+	// recent core dumps are listed, then their core files are checked
+	sinceArg := "--since=@" + strconv.FormatInt(since.Unix(), 10)
+	output, err := exec.Command("coredumpctl", "--no-pager", sinceArg,
+		"--field=COREDUMP_PID", "list").Output()
+	if err != nil {
+		return coreProcess{}, fmt.Errorf("coredumpctl failed: %s", err)
+	}
+
+	for _, pid := range strings.Fields(string(output)) {
+		cmd := exec.Command("coredumpctl", "dump", "--quiet", "--no-pager",
+			sinceArg, "--output="+destination, pid)
+		if err := cmd.Run(); err != nil {
+			os.Remove(destination)
+			continue
+		}
+
+		process, err := readCoreProcess(destination)
+		if err == nil && process.PGID == pgid {
+			return process, nil
+		}
+		os.Remove(destination)
+	}
+
+	return coreProcess{}, fmt.Errorf("No core dump found by coredumpctl "+
+		"for process group %d", pgid)
+}
+
+// Moves a file, copying it if it cannot be renamed (other filesystem...)
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	_, copyErr := io.Copy(out, in)
+	closeErr := out.Close()
+	if copyErr != nil || closeErr != nil {
+		os.Remove(destination)
+		return fmt.Errorf("Cannot copy '%s' to '%s'", source, destination)
+	}
+
+	os.Remove(source)
+	return nil
+}
+
+// Collects the core file of a crashed process into the crash directory.
+// The core pattern is a system-wide setting that robin does not change:
+// the kernel writes core files where the pattern says, then they are moved
+// into the crash directory.
+func collectCoreFile(exp Experiment, name string, crash *CrashInfo,
+	since time.Time) error {
+	if err := CreateDirIfNeeded(CrashDir(exp.OutputDir)); err != nil {
+		return err
+	}
+
+	destination := func(pid int) string {
+		return fmt.Sprintf("%s/core.%s.%d", CrashDir(exp.OutputDir),
+			strings.ToLower(name), pid)
+	}
+
+	coreFile, core, err := findCoreFile(since, crash.WrapperPID)
+	if err != nil {
+		pattern, _ := ioutil.ReadFile(corePatternFile)
+		if !strings.HasPrefix(string(pattern), "|") {
+			return err
+		}
+
+		dumped := destination(crash.WrapperPID) + ".tmp"
+		core, err = dumpCoreWithCoredumpctl(since, crash.WrapperPID, dumped)
+		if err != nil {
+			return err
+		}
+		coreFile = dumped
+	}
+
+	crash.PID = core.PID
+	crash.Executable = core.Executable
+	if err := moveFile(coreFile, destination(core.PID)); err != nil {
+		// The core file is still usable where the kernel wrote it
+		log.WithFields(log.Fields{
+			"err":       err,
+			"core file": coreFile,
+		}).Warning("Cannot move core file into the crash directory")
+		crash.CoreFile = coreFile
+		return nil
+	}
+
+	crash.CoreFile = destination(core.PID)
+	return nil
+}
+
+// Writes the backtrace of a core file with gdb
+func writeBacktrace(command string, crash *CrashInfo) error {
+	if _, err := exec.LookPath("gdb"); err != nil {
+		return fmt.Errorf("gdb is not available")
+	}
+
+	// The crashed program is assumed to be the command's first word
+	args := []string{"-batch", "-ex", "bt"}
+	if fields := strings.Fields(command); len(fields) > 0 {
+		if program, err := exec.LookPath(fields[0]); err == nil {
+			args = append(args, program)
+		}
+	}
+	args = append(args, "-core", crash.CoreFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), backtraceTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "gdb", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("gdb failed: %s", err)
+	}
+
+	backtraceFile := crash.CoreFile + ".backtrace"
+	if err := ioutil.WriteFile(backtraceFile, output, 0644); err != nil {
+		return err
+	}
+	crash.BacktraceFile = backtraceFile
+	return nil
+}
+
+// Collects the core files (and optionally their backtraces) of the crashed
+// processes of an execution
+func (record *executionRecord) collectCrashes(exp Experiment) {
+	commands := map[string]string{
+		"Batsim":    exp.Batcmd,
+		"Scheduler": exp.Schedcmd,
+	}
+
+	for name, processResult := range record.processes {
+		crash := processResult.Crash
+		if crash == nil {
+			continue
+		}
+
+		if err := collectCoreFile(exp, name, crash,
+			record.timeline.Begin); err != nil {
+			log.WithFields(log.Fields{
+				"err":          err,
+				"process name": name,
+				"wrapper pid":  crash.WrapperPID,
+				"signal":       crash.Signal,
+			}).Warning("Cannot collect core file")
+			continue
+		}
+
+		if exp.CoreBacktrace {
+			if err := writeBacktrace(commands[name], crash); err != nil {
+				log.WithFields(log.Fields{
+					"err":          err,
+					"process name": name,
+					"core file":    crash.CoreFile,
+				}).Warning("Cannot generate backtrace")
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"process name":   name,
+			"pid":            crash.PID,
+			"executable":     crash.Executable,
+			"signal":         crash.Signal,
+			"core file":      crash.CoreFile,
+			"backtrace file": crash.BacktraceFile,
+		}).Info("Crash artifacts collected")
+	}
+}
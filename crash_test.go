@@ -0,0 +1,65 @@
+package batexpe
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Starts a process in its own process group, as robin starts its commands,
+// then makes it dump a core
+func crashInOwnGroup(t *testing.T) int {
+	cmd := exec.Command("sleep", "10")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Process.Signal(syscall.SIGSEGV)
+
+	if _, signal := ExitStatus(cmd.Wait()); signal != syscall.SIGSEGV {
+		t.Fatalf("Unexpected signal: %s", signal)
+	}
+	return cmd.Process.Pid
+}
+
+func TestFindCoreFile(t *testing.T) {
+	pattern, err := ioutil.ReadFile(corePatternFile)
+	if err != nil || strings.HasPrefix(string(pattern), "|") ||
+		strings.HasPrefix(string(pattern), "/") {
+		t.Skip("Core files are not written in the working directory")
+	}
+
+	t.Chdir(t.TempDir())
+	restoreCoreLimit := enableCoreDumps()
+	defer restoreCoreLimit()
+
+	since := time.Now().Add(-time.Second)
+	first := crashInOwnGroup(t)
+	second := crashInOwnGroup(t)
+
+	coreFile, core, err := findCoreFile(since, second)
+	if err != nil {
+		t.Skipf("No core file dumped: %s", err)
+	}
+	if core.PID != second || core.PGID != second {
+		t.Errorf("Unexpected core process: expected PID=PGID=%d, got %+v",
+			second, core)
+	}
+	if core.Executable != "sleep" {
+		t.Errorf("Unexpected core executable: %s", core.Executable)
+	}
+
+	// Without PID in the core pattern, the second core overwrites the first
+	firstCoreFile, core, err := findCoreFile(since, first)
+	if err == nil && (firstCoreFile == coreFile || core.PID != first) {
+		t.Errorf("Core file %s (%+v) credited to process %d", firstCoreFile,
+			core, first)
+	}
+
+	if _, _, err := findCoreFile(since, first+second); err == nil {
+		t.Errorf("Core file found for an unrelated process group")
+	}
+}
@@ -0,0 +1,218 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period at which the socket of a manually started process is checked
+const manualProcessPollPeriod = 50 * time.Millisecond
+
+// Returns whether a process should be started manually by the user
+func isManualProcess(opts ExecuteOptions, name string) bool {
+	return opts.DebugProcess == name && opts.DebugWrapper == ""
+}
+
+// Checks that the debug options are consistent with the experiment
+func checkDebugOptions(exp Experiment, batargs BatsimArgs,
+	opts ExecuteOptions) error {
+	if opts.DebugProcess == "" {
+		if opts.DebugWrapper != "" {
+			log.WithFields(log.Fields{
+				"debug wrapper": opts.DebugWrapper,
+			}).Error("Debug wrapper set without debugged process")
+			return fmt.Errorf("Debug wrapper set without debugged process")
+		}
+		return nil
+	}
+
+	if opts.DebugProcess != "Batsim" && opts.DebugProcess != "Scheduler" {
+		log.WithFields(log.Fields{
+			"debugged process": opts.DebugProcess,
+		}).Error("Invalid debugged process (expected Batsim or Scheduler)")
+		return fmt.Errorf("Invalid debugged process")
+	}
+
+	if opts.DebugProcess == "Scheduler" && exp.Schedcmd == "" {
+		log.WithFields(log.Fields{
+			"debugged process": opts.DebugProcess,
+		}).Error("Cannot debug the scheduler: there is no scheduler")
+		return fmt.Errorf("Cannot debug the scheduler: there is no scheduler")
+	}
+
+	if opts.DebugWrapper == "" {
+		// The manually started process is only followed via the socket
+		if batargs.BatexecMode || !strings.HasPrefix(batargs.Socket, "tcp") {
+			log.WithFields(log.Fields{
+				"debugged process": opts.DebugProcess,
+				"socket endpoint":  batargs.Socket,
+				"batexec mode":     batargs.BatexecMode,
+			}).Error("Processes can only be started manually with a TCP " +
+				"socket and a scheduler (use a debug wrapper instead)")
+			return fmt.Errorf("Processes can only be started manually with " +
+				"a TCP socket and a scheduler")
+		}
+	}
+
+	return nil
+}
+
+// Returns the command prefix of a debug wrapper.
+// gdb and valgrind are known wrappers, any other wrapper is used verbatim.
+func debugWrapperPrefix(exp Experiment, name, wrapper string) string {
+	switch wrapper {
+	case "gdb":
+		return "gdb -batch -ex run -ex bt --args"
+	case "valgrind":
+		return fmt.Sprintf("valgrind --log-file=%s/%s.valgrind.log",
+			exp.LogDir(), strings.ToLower(name))
+	default:
+		return wrapper
+	}
+}
+
+// Inserts a wrapper before the program of a command.
+// Leading environment variable assignments are kept before the wrapper.
+func wrapCommand(command, wrapper string) string {
+	r := regexp.MustCompile(`^(\s*(\w+=\S*\s+)*)`)
+	envPrefix := r.FindString(command)
+	return envPrefix + wrapper + " " + command[len(envPrefix):]
+}
+
+// Wraps the command of the debugged process if a debug wrapper is set
+func wrapDebuggedCommand(exp Experiment, opts ExecuteOptions) Experiment {
+	if opts.DebugProcess == "" || opts.DebugWrapper == "" {
+		return exp
+	}
+
+	prefix := debugWrapperPrefix(exp, opts.DebugProcess, opts.DebugWrapper)
+	if opts.DebugProcess == "Batsim" {
+		exp.Batcmd = wrapCommand(exp.Batcmd, prefix)
+	} else {
+		exp.Schedcmd = wrapCommand(exp.Schedcmd, prefix)
+	}
+
+	log.WithFields(log.Fields{
+		"debugged process":  opts.DebugProcess,
+		"debug wrapper":     opts.DebugWrapper,
+		"batsim command":    exp.Batcmd,
+		"scheduler command": exp.Schedcmd,
+	}).Info("Debugged process wrapped")
+
+	return exp
+}
+
+// Returns whether a TCP port is listened to and whether connections on it
+// are established
+func tcpPortStates(port uint16) (listening, established bool, err error) {
+	cmdName, cmdArgs := "ss", []string{"-tan"}
+	if _, lookErr := exec.LookPath("ss"); lookErr != nil ||
+		runtime.GOOS == "darwin" {
+		cmdName, cmdArgs = "netstat", []string{"-an", "-p", "tcp"}
+	}
+
+	outBuf, err := exec.Command(cmdName, cmdArgs...).Output()
+	if err != nil {
+		return false, false, fmt.Errorf("Cannot list open sockets via %s: %s",
+			cmdName, err)
+	}
+
+	r := regexp.MustCompile(`[:.]` + strconv.FormatUint(uint64(port), 10) +
+		`\s`)
+	for _, line := range strings.Split(string(outBuf), "\n") {
+		if !r.MatchString(line + " ") {
+			continue
+		}
+		if strings.Contains(line, "LISTEN") {
+			listening = true
+		} else if strings.Contains(line, "ESTAB") {
+			established = true
+		}
+	}
+	return listening, established, nil
+}
+
+// Prints how to start a process manually
+func printManualStartInstructions(name, cmdString, cmdFile string) {
+	fmt.Fprintf(os.Stderr, "\nStart %s manually, for example with:\n"+
+		"  bash -eux %s\n"+
+		"Its exact command is:\n"+
+		"  %s\n\n", name, cmdFile, cmdString)
+}
+
+// Replaces ExecuteTimeout for a process started manually by the user.
+// The process is followed via the socket: the scheduler is running while
+// it listens to the socket, Batsim while it is connected to it.
+// As the process has not been started by robin, its exit status is unknown
+// and it cannot be killed.
+func watchManualProcess(name, cmdString, cmdFile string, batargs BatsimArgs,
+	timeout float64, onstart chan CmdFinishedMsg,
+	onexit chan CmdFinishedMsg) {
+	port, err := PortFromBatSock(batargs.Socket)
+	if err != nil {
+		onstart <- CmdFinishedMsg{Name: name, State: FAILURE, Err: err}
+		onexit <- CmdFinishedMsg{Name: name, State: FAILURE, Err: err}
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"process name": name,
+		"command":      cmdString,
+		"command file": cmdFile,
+		"port":         port,
+	}).Warning("Waiting for the process to be started manually")
+	printManualStartInstructions(name, cmdString, cmdFile)
+	onstart <- CmdFinishedMsg{Name: name, State: SUCCESS}
+
+	timeoutReached := time.After(time.Duration(timeout * float64(time.Second)))
+	started := false
+	for {
+		select {
+		case <-timeoutReached:
+			log.WithFields(log.Fields{
+				"process name": name,
+				"timeout":      timeout,
+			}).Error("Manually started process did not finish before " +
+				"simulation timeout (it must be stopped manually)")
+			onexit <- CmdFinishedMsg{Name: name, State: TIMEOUT}
+			return
+		case <-time.After(manualProcessPollPeriod):
+		}
+
+		listening, established, err := tcpPortStates(port)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":          err,
+				"process name": name,
+			}).Error("Cannot watch manually started process")
+			onexit <- CmdFinishedMsg{Name: name, State: FAILURE, Err: err}
+			return
+		}
+
+		running := listening
+		if name == "Batsim" {
+			running = established
+		}
+
+		if running && !started {
+			started = true
+			log.WithFields(log.Fields{
+				"process name": name,
+			}).Info("Manually started process detected")
+		} else if !running && started {
+			log.WithFields(log.Fields{
+				"process name": name,
+			}).Info("Manually started process finished " +
+				"(exit status unknown)")
+			onexit <- CmdFinishedMsg{Name: name, State: SUCCESS}
+			return
+		}
+	}
+}
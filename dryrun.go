@@ -0,0 +1,120 @@
+package batexpe
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+)
+
+// What robin would execute for a simulation
+type ExecutionPlan struct {
+	BatsimCommand     string  `json:"batsim-command"`
+	BatsimCmdFile     string  `json:"batsim-cmdfile"`
+	BatsimLogFile     string  `json:"batsim-logfile"`
+	SchedCommand      string  `json:"scheduler-command,omitempty"`
+	SchedCmdFile      string  `json:"scheduler-cmdfile,omitempty"`
+	SchedOutLogFile   string  `json:"scheduler-logfile-out,omitempty"`
+	SchedErrLogFile   string  `json:"scheduler-logfile-err,omitempty"`
+	BatexecMode       bool    `json:"batexec-mode"`
+	SocketEndpoint    string  `json:"socket-endpoint,omitempty"`
+	ExportPrefix      string  `json:"export-prefix"`
+	SimulationTimeout float64 `json:"simulation-timeout"`
+	ReadyTimeout      float64 `json:"ready-timeout"`
+	SuccessTimeout    float64 `json:"success-timeout"`
+	FailureTimeout    float64 `json:"failure-timeout"`
+	StallTimeout      float64 `json:"stall-timeout,omitempty"`
+	TotalTimeout      float64 `json:"total-timeout,omitempty"`
+	ContextReady      bool    `json:"context-ready"`
+}
+
+func NewExecutionPlan(exp Experiment, batargs BatsimArgs,
+	contextReady bool) ExecutionPlan {
+	plan := ExecutionPlan{
+		BatsimCommand:     exp.Batcmd,
+		BatsimCmdFile:     exp.OutputDir + "/cmd/batsim.bash",
+		BatsimLogFile:     exp.LogDir() + "/batsim.log",
+		BatexecMode:       batargs.BatexecMode,
+		ExportPrefix:      batargs.ExportPrefix,
+		SimulationTimeout: exp.SimulationTimeout,
+		ReadyTimeout:      exp.ReadyTimeout,
+		SuccessTimeout:    exp.SuccessTimeout,
+		FailureTimeout:    exp.FailureTimeout,
+		StallTimeout:      exp.StallTimeout,
+		TotalTimeout:      exp.TotalTimeout,
+		ContextReady:      contextReady,
+	}
+
+	if !batargs.BatexecMode {
+		plan.SchedCommand = exp.Schedcmd
+		plan.SchedCmdFile = exp.OutputDir + "/cmd/sched.bash"
+		plan.SchedOutLogFile = exp.LogDir() + "/sched.out.log"
+		plan.SchedErrLogFile = exp.LogDir() + "/sched.err.log"
+		plan.SocketEndpoint = batargs.Socket
+	}
+
+	return plan
+}
+
+// Writes the command files of a plan
+func (plan ExecutionPlan) writeCommandFiles() error {
+	err := ioutil.WriteFile(plan.BatsimCmdFile, []byte(plan.BatsimCommand),
+		0755)
+	if err == nil && plan.SchedCmdFile != "" {
+		err = ioutil.WriteFile(plan.SchedCmdFile, []byte(plan.SchedCommand),
+			0755)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":               err,
+			"batsim cmdfile":    plan.BatsimCmdFile,
+			"scheduler cmdfile": plan.SchedCmdFile,
+		}).Error("Cannot create file")
+		return fmt.Errorf("Cannot create file")
+	}
+	return nil
+}
+
+// Prints a plan on stdout, as JSON if logs are in JSON, as YAML otherwise
+func (plan ExecutionPlan) print() error {
+	var byt []byte
+	var err error
+	if isJSONLogging() {
+		byt, err = json.Marshal(plan)
+	} else {
+		byt, err = yaml.Marshal(plan)
+	}
+
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(byt))
+	return nil
+}
+
+// Finishes a dry run: writes the command files and prints the plan instead
+// of executing the processes
+func executeDryRun(exp Experiment, batargs BatsimArgs,
+	contextReady bool) int {
+	plan := NewExecutionPlan(exp, batargs, contextReady)
+	if err := plan.writeCommandFiles(); err != nil {
+		return 1
+	}
+
+	if err := plan.print(); err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot print execution plan")
+		return 1
+	}
+
+	if !contextReady {
+		log.Error("Dry run: the context is not ready for the simulation")
+		return 1
+	}
+
+	log.Info("Dry run: no process has been started")
+	return 0
+}
@@ -0,0 +1,201 @@
+package batexpe
+
+import (
+	"strings"
+	"time"
+)
+
+// Stable identifiers of the robin log entries that describe what happened
+// during a simulation. They are logged in the "event" field of the entries.
+// Unlike log messages, they are not meant to be changed.
+const (
+	EventSimulationStart    = "simulation-start"
+	EventContextInvalid     = "context-invalid"
+	EventProcessStartFailed = "process-start-failed"
+	EventProcessSucceeded   = "process-succeeded"
+	EventProcessFailed      = "process-failed"
+	// A process has been killed as it reached the simulation timeout
+	EventProcessTimeout = "process-timeout"
+	EventSuccessTimeout = "success-timeout"
+	EventFailureTimeout = "failure-timeout"
+	EventTotalTimeout   = "total-timeout"
+	EventStall          = "stall"
+	// A failed attempt is about to be executed again
+	EventRetry         = "retry"
+	EventRobinFinished = "robin-finished"
+)
+
+// One entry of robin's JSON log
+type RobinEvent struct {
+	// Stable identifier of the entry (one of the Event* values).
+	// Empty if the entry is not an event.
+	Kind        string
+	Level       string
+	Message     string
+	ProcessName string
+	// Zero if the entry has no valid time
+	Time time.Time
+	// All the fields of the entry, including the ones above
+	Fields map[string]interface{}
+}
+
+// The entries of one robin log
+type RobinEvents []RobinEvent
+
+func NewRobinEvent(fields map[string]interface{}) RobinEvent {
+	event := RobinEvent{Fields: fields}
+	event.Kind = event.StringField("event")
+	event.Level = event.StringField("level")
+	event.Message = event.StringField("msg")
+	event.ProcessName = event.StringField("process name")
+	event.Time, _ = time.Parse(time.RFC3339Nano, event.StringField("time"))
+	return event
+}
+
+// Converts the lines returned by ParseRobinOutput.
+// Lines that are not JSON objects are skipped.
+func NewRobinEvents(robinJsonLines []interface{}) RobinEvents {
+	events := make(RobinEvents, 0, len(robinJsonLines))
+	for _, object := range robinJsonLines {
+		if fields, isMap := object.(map[string]interface{}); isMap {
+			events = append(events, NewRobinEvent(fields))
+		}
+	}
+	return events
+}
+
+// Parses robin's JSON log (as printed with --json-logs)
+func ParseRobinEvents(output string) (RobinEvents, error) {
+	robinJsonLines, err := ParseRobinOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	return NewRobinEvents(robinJsonLines), nil
+}
+
+// Returns a field of an entry as a string.
+// Returns an empty string if the field is missing or is not a string.
+func (event RobinEvent) StringField(name string) string {
+	value, _ := event.Fields[name].(string)
+	return value
+}
+
+// Returns a numeric field of an entry
+func (event RobinEvent) FloatField(name string) (value float64, found bool) {
+	value, found = event.Fields[name].(float64)
+	return value, found
+}
+
+// Returns whether a field of an entry lists a name (comma-separated list)
+func (event RobinEvent) listsName(field, name string) bool {
+	for _, item := range SplitList(event.StringField(field)) {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the entries of the last attempt of the simulation.
+// The entries of all the attempts are returned if robin did not retry it.
+func (events RobinEvents) LastAttempt() RobinEvents {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Kind == EventRetry {
+			return events[i+1:]
+		}
+	}
+	return events
+}
+
+// Returns how a process finished during the last attempt: whether it
+// succeeded, and whether it has been killed (by a signal or by the
+// simulation timeout)
+func (events RobinEvents) ProcessOutcome(processName string) (successful,
+	killed bool) {
+	for _, event := range events.LastAttempt() {
+		if event.ProcessName != processName {
+			continue
+		}
+
+		switch event.Kind {
+		case EventProcessSucceeded:
+			return true, false
+		case EventProcessFailed:
+			return false, strings.HasPrefix(event.StringField("err"),
+				"signal: ")
+		case EventProcessTimeout:
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+// Returns whether the last attempt of the simulation has been started with
+// a scheduler
+func (events RobinEvents) SchedPresent() bool {
+	present := false
+	for _, event := range events.LastAttempt() {
+		if event.Kind == EventSimulationStart {
+			_, present = event.Fields["scheduler command"]
+		}
+	}
+	return present
+}
+
+// Returns whether the execution context was clean when robin tried to
+// start the last attempt of the simulation
+func (events RobinEvents) ContextClean() bool {
+	for _, event := range events.LastAttempt() {
+		switch event.Kind {
+		case EventSimulationStart:
+			return true
+		case EventContextInvalid:
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the duration of robin's execution as logged by robin.
+// found is false if robin did not log it (e.g. if robin has been killed).
+func (events RobinEvents) Duration() (duration float64, found bool) {
+	for _, event := range events {
+		if event.Kind == EventRobinFinished {
+			return event.FloatField("duration (seconds)")
+		}
+	}
+	return 0, false
+}
+
+// Returns which timeout made robin kill a process during the last attempt
+// (simulation-timeout, success-timeout, failure-timeout, total-timeout or
+// stall-timeout).
+// Returns an empty string if the process has not been killed by a timeout.
+func (events RobinEvents) KillingTimeout(processName string) string {
+	for _, event := range events.LastAttempt() {
+		switch event.Kind {
+		case EventProcessTimeout:
+			if event.ProcessName == processName {
+				return "simulation-timeout"
+			}
+		case EventSuccessTimeout:
+			if event.StringField("victim name") == processName {
+				return "success-timeout"
+			}
+		case EventFailureTimeout:
+			if event.StringField("victim name") == processName {
+				return "failure-timeout"
+			}
+		case EventTotalTimeout:
+			if event.listsName("victim names", processName) {
+				return "total-timeout"
+			}
+		case EventStall:
+			if event.listsName("victim names", processName) {
+				return "stall-timeout"
+			}
+		}
+	}
+	return ""
+}
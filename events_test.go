@@ -0,0 +1,100 @@
+package batexpe
+
+import (
+	"strings"
+	"testing"
+)
+
+// JSON log entries of robin, as printed with --json-logs
+const (
+	contextInvalidEntry = `{"event":"context-invalid","level":"error",` +
+		`"msg":"Context remains invalid"}`
+	batsimFailedEntry = `{"event":"process-failed","level":"error",` +
+		`"msg":"Simulation subprocess failed","process name":"Batsim",` +
+		`"err":"signal: segmentation fault"}`
+	schedFailedEntry = `{"event":"process-failed","level":"error",` +
+		`"msg":"Simulation subprocess failed","process name":"Scheduler",` +
+		`"err":"exit status 1"}`
+	batsimTimeoutEntry = `{"event":"process-timeout","level":"error",` +
+		`"msg":"Simulation subprocess timeout reached",` +
+		`"process name":"Batsim"}`
+	failureTimeoutEntry = `{"event":"failure-timeout","level":"warning",` +
+		`"msg":"Failure timeout reached","victim name":"Batsim"}`
+	retryEntry = `{"event":"retry","level":"warning",` +
+		`"msg":"Simulation attempt failed. Retrying","attempt":1}`
+	startEntry = `{"event":"simulation-start","level":"info",` +
+		`"msg":"Starting simulation","scheduler command":"sched"}`
+	batsimSucceededEntry = `{"event":"process-succeeded","level":"info",` +
+		`"msg":"Simulation subprocess succeeded","process name":"Batsim"}`
+	schedSucceededEntry = `{"event":"process-succeeded","level":"info",` +
+		`"msg":"Simulation subprocess succeeded","process name":"Scheduler"}`
+	finishedEntry = `{"event":"robin-finished","level":"info",` +
+		`"msg":"Robin execution finished","duration (seconds)":3.5}`
+)
+
+func TestRobinEventsAttempts(t *testing.T) {
+	tests := []struct {
+		name           string
+		entries        []string
+		batsimSuccess  bool
+		batsimKilled   bool
+		schedPresent   bool
+		contextClean   bool
+		batsimKiller   string
+		schedSuccess   bool
+		durationLogged bool
+	}{
+		{"single-attempt", []string{startEntry, batsimSucceededEntry,
+			schedSucceededEntry, finishedEntry},
+			true, false, true, true, "", true, true},
+		{"invalid-context-then-success", []string{contextInvalidEntry,
+			retryEntry, startEntry, batsimSucceededEntry, schedSucceededEntry,
+			finishedEntry},
+			true, false, true, true, "", true, true},
+		{"failure-then-success", []string{startEntry, schedFailedEntry,
+			failureTimeoutEntry, batsimFailedEntry, retryEntry, startEntry,
+			batsimSucceededEntry, schedSucceededEntry, finishedEntry},
+			true, false, true, true, "", true, true},
+		{"timeout-then-invalid-context", []string{startEntry,
+			batsimTimeoutEntry, schedSucceededEntry, retryEntry,
+			contextInvalidEntry, finishedEntry},
+			false, false, false, false, "", false, true},
+		{"success-then-timeout", []string{startEntry, batsimSucceededEntry,
+			schedSucceededEntry, retryEntry, startEntry, batsimTimeoutEntry,
+			schedFailedEntry},
+			false, true, true, true, "simulation-timeout", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := ParseRobinEvents(strings.Join(test.entries, "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			success, killed := events.ProcessOutcome("Batsim")
+			if success != test.batsimSuccess || killed != test.batsimKilled {
+				t.Errorf("Unexpected Batsim outcome: expected success=%t "+
+					"killed=%t, got success=%t killed=%t", test.batsimSuccess,
+					test.batsimKilled, success, killed)
+			}
+			if success, _ := events.ProcessOutcome("Scheduler"); success !=
+				test.schedSuccess {
+				t.Errorf("Unexpected scheduler success: %t", success)
+			}
+			if present := events.SchedPresent(); present != test.schedPresent {
+				t.Errorf("Unexpected scheduler presence: %t", present)
+			}
+			if clean := events.ContextClean(); clean != test.contextClean {
+				t.Errorf("Unexpected context cleanliness: %t", clean)
+			}
+			if killer := events.KillingTimeout("Batsim"); killer !=
+				test.batsimKiller {
+				t.Errorf("Unexpected Batsim killing timeout: '%s'", killer)
+			}
+			if _, found := events.Duration(); found != test.durationLogged {
+				t.Errorf("Unexpected duration presence: %t", found)
+			}
+		})
+	}
+}
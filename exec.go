@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -19,24 +20,55 @@ const (
 	SUCCESS int = iota
 	TIMEOUT
 	FAILURE
+	ABORTED
+	STALLED
 )
 
 type CmdFinishedMsg struct {
 	Name  string
 	State int
+	// PID of the process (0 if it could not be started)
+	PID int
+	// Error returned by the process (nil on success or timeout)
+	Err error
+	// Category of the error (nil unless the process failed)
+	Classification *ErrorClassification
+}
+
+// Options on how robin executes an experiment.
+// Contrary to the Experiment fields, they do not change the simulation itself.
+type ExecuteOptions struct {
+	// Preview the logs of failed processes on stderr
+	PreviewOnError bool
+	// Period (in seconds) of the progress log entries. 0 disables them.
+	ProgressInterval float64
+	// Write a live one-line progress status on stderr (if it is a terminal)
+	LiveStatus bool
+	// Also stream the processes' outputs on stdout, prefixed by their name
+	Tee bool
+	// Colorize the teed outputs
+	TeeColor bool
+	// Check everything and print the execution plan, but start no process
+	DryRun bool
+	// Process to debug ("Batsim" or "Scheduler"). Without DebugWrapper,
+	// robin does not start it but waits for the user to start it manually.
+	DebugProcess string
+	// Wrapper of the debugged process ("gdb", "valgrind" or any command
+	// prefix)
+	DebugWrapper string
 }
 
 func PrepareDirs(exp Experiment) error {
 	// Create output directory if needed
 	outErr := CreateDirIfNeeded(exp.OutputDir)
-	logErr := CreateDirIfNeeded(exp.OutputDir + "/log")
+	logErr := CreateDirIfNeeded(exp.LogDir())
 	cmdErr := CreateDirIfNeeded(exp.OutputDir + "/cmd")
 
 	if (outErr != nil) || (logErr != nil) || (cmdErr != nil) {
 		log.WithFields(log.Fields{
 			"output-dir":     exp.OutputDir,
 			"output-dir_err": outErr,
-			"log-dir":        exp.OutputDir + "/log",
+			"log-dir":        exp.LogDir(),
 			"log-dir_err":    logErr,
 			"cmd-dir":        exp.OutputDir + "/cmd",
 			"cmd-dir_err":    cmdErr,
@@ -47,7 +79,8 @@ func PrepareDirs(exp Experiment) error {
 	return nil
 }
 
-func waitReadyForSimulation(exp Experiment, batargs BatsimArgs) error {
+func waitReadyForSimulation(exp Experiment, batargs BatsimArgs,
+	record *executionRecord) error {
 	log.WithFields(log.Fields{
 		"ready timeout (seconds)":   exp.ReadyTimeout,
 		"extracted socket endpoint": batargs.Socket,
@@ -74,10 +107,15 @@ func waitReadyForSimulation(exp Experiment, batargs BatsimArgs) error {
 		go waitTcpPortAvailableSs(port, sockChan)
 		go waitNoConflictingBatsim(batargs, batChan)
 
+		totalTimeout := record.totalTimeoutReached()
 		for socketInUse || anotherBatsim {
 			select {
+			case <-totalTimeout:
+				record.stopOnTotalTimeout(exp, "")
+				return fmt.Errorf("Total timeout reached")
 			case <-time.After(time.Duration(exp.ReadyTimeout) * time.Second):
 				log.WithFields(log.Fields{
+					"event":                      EventContextInvalid,
 					"ready timeout (seconds)":    exp.ReadyTimeout,
 					"scanned port":               port,
 					"batsim command":             exp.Batcmd,
@@ -166,42 +204,67 @@ func waitNoConflictingBatsim(batargsToLaunch BatsimArgs, onexit chan int) {
 	}
 }
 
-func waitTcpPortAvailableSs(port uint16, onexit chan int) {
-	portStr := strconv.FormatUint(uint64(port), 10)
-	r := regexp.MustCompile(":" + portStr)
-
-	// Determine which command to use with fallback logic
-	var cmdName string
-	var cmdArgs []string
-
+// Returns the command that lists the listening TCP sockets
+func socketListingCommand() (cmdName string, cmdArgs []string, err error) {
 	// Prefer netstat on macOS, ss on Linux
 	if runtime.GOOS == "darwin" {
 		// macOS: try netstat first
 		if _, err := exec.LookPath("netstat"); err == nil {
-			cmdName = "netstat"
-			cmdArgs = []string{"-an", "-p", "tcp"}
+			return "netstat", []string{"-an", "-p", "tcp"}, nil
 		} else if _, err := exec.LookPath("ss"); err == nil {
 			// Fallback to ss if netstat is not available
-			cmdName = "ss"
-			cmdArgs = []string{"-tln"}
-		} else {
-			log.Error("Neither netstat nor ss command is available")
-			onexit <- 1
-			return
-		}
-	} else {
-		// Linux and others: try ss first, fallback to netstat
-		if _, err := exec.LookPath("ss"); err == nil {
-			cmdName = "ss"
-			cmdArgs = []string{"-tln"}
-		} else if _, err := exec.LookPath("netstat"); err == nil {
-			cmdName = "netstat"
-			cmdArgs = []string{"-an", "-p", "tcp"}
-		} else {
-			log.Error("Neither ss nor netstat command is available")
-			onexit <- 1
-			return
+			return "ss", []string{"-tln"}, nil
 		}
+		log.Error("Neither netstat nor ss command is available")
+		return "", nil, fmt.Errorf("Neither netstat nor ss command is available")
+	}
+
+	// Linux and others: try ss first, fallback to netstat
+	if _, err := exec.LookPath("ss"); err == nil {
+		return "ss", []string{"-tln"}, nil
+	} else if _, err := exec.LookPath("netstat"); err == nil {
+		return "netstat", []string{"-an", "-p", "tcp"}, nil
+	}
+	log.Error("Neither ss nor netstat command is available")
+	return "", nil, fmt.Errorf("Neither ss nor netstat command is available")
+}
+
+// Returns whether a TCP port is in the sockets listed by cmdName
+func isTcpPortListed(port uint16, cmdName string, cmdArgs []string) (bool,
+	error) {
+	portStr := strconv.FormatUint(uint64(port), 10)
+	r := regexp.MustCompile(":" + portStr)
+
+	cmd := exec.Command(cmdName, cmdArgs...)
+
+	outBuf, err := cmd.Output()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":     err,
+			"command": cmdName,
+			"args":    cmdArgs,
+		}).Error(fmt.Sprintf("Cannot list open sockets via %s", cmdName))
+		return false, err
+	}
+
+	return r.Match(outBuf), nil
+}
+
+// Returns whether a TCP port is in use, according to ss or netstat
+func IsTcpPortInUse(port uint16) (bool, error) {
+	cmdName, cmdArgs, err := socketListingCommand()
+	if err != nil {
+		return false, err
+	}
+	return isTcpPortListed(port, cmdName, cmdArgs)
+}
+
+func waitTcpPortAvailableSs(port uint16, onexit chan int) {
+	// Determine which command to use with fallback logic
+	cmdName, cmdArgs, err := socketListingCommand()
+	if err != nil {
+		onexit <- 1
+		return
 	}
 
 	log.WithFields(log.Fields{
@@ -212,20 +275,13 @@ func waitTcpPortAvailableSs(port uint16, onexit chan int) {
 	}).Debug("Using network command for port checking")
 
 	for {
-		cmd := exec.Command(cmdName, cmdArgs...)
-
-		outBuf, err := cmd.Output()
+		inUse, err := isTcpPortListed(port, cmdName, cmdArgs)
 		if err != nil {
-			log.WithFields(log.Fields{
-				"err":     err,
-				"command": cmdName,
-				"args":    cmdArgs,
-			}).Error(fmt.Sprintf("Cannot list open sockets via %s", cmdName))
 			onexit <- 1
 			return
 		}
 
-		if !(r.Match(outBuf)) {
+		if !inUse {
 			onexit <- 0
 			return
 		} else {
@@ -234,11 +290,13 @@ func waitTcpPortAvailableSs(port uint16, onexit chan int) {
 	}
 }
 
-func logExecuteTimeoutError(errMsg string, err error,
+func logExecuteTimeoutError(event, errMsg string, err error,
+	classification *ErrorClassification,
 	name, cmdString, cmdFile, stdoutFile, stderrFile string,
 	cmd *exec.Cmd, timeout float64, previewOnError bool) {
 
-	log.WithFields(log.Fields{
+	fields := log.Fields{
+		"event":                        event,
 		"process name":                 name,
 		"err":                          err,
 		"command":                      cmdString,
@@ -246,24 +304,49 @@ func logExecuteTimeoutError(errMsg string, err error,
 		"stdout file":                  stdoutFile,
 		"stderr file":                  stderrFile,
 		"simulation timeout (seconds)": timeout,
-	}).Error(errMsg)
+	}
+
+	if classification != nil {
+		fields["error category"] = classification.Category
+		fields["error excerpt"] = classification.Excerpt
+	}
 
-	// If the option is set, preview simulation logs to stderr
+	// If the option is set, preview simulation logs
+	var outPreview, errPreview FilePreview
+	var outPreviewErr, errPreviewErr error
 	if previewOnError {
 		var linesToPreview int64 = 20
 		// Preview stdout log unless set to /dev/null (batsim process)
 		if stdoutFile != "/dev/null" {
-			outPreview, err := PreviewFile(stdoutFile, linesToPreview)
-			if err == nil {
-				if outPreview != "" {
+			outPreview, outPreviewErr = ReadFilePreview(stdoutFile,
+				linesToPreview)
+			if outPreviewErr == nil && isJSONLogging() {
+				fields["stdout preview"] = outPreview
+			}
+		}
+
+		errPreview, errPreviewErr = ReadFilePreview(stderrFile,
+			linesToPreview)
+		if errPreviewErr == nil && isJSONLogging() {
+			fields["stderr preview"] = errPreview
+		}
+	}
+
+	log.WithFields(fields).Error(errMsg)
+
+	// Text previews are written on stderr, so that stdout keeps its structure
+	if previewOnError {
+		if stdoutFile != "/dev/null" {
+			if outPreviewErr == nil {
+				if len(outPreview.HeadLines) > 0 {
 					fmt.Fprintf(os.Stderr,
 						"\nContent of %s's stdout log:\n%s\n",
-						name, outPreview)
+						name, outPreview.String())
 				}
 			} else {
 				log.WithFields(log.Fields{
 					"process name": name,
-					"err":          err,
+					"err":          outPreviewErr,
 					"command":      cmdString,
 					"command file": cmdFile,
 					"stdout file":  stdoutFile,
@@ -271,17 +354,15 @@ func logExecuteTimeoutError(errMsg string, err error,
 			}
 		}
 
-		// Preview stderr
-		errPreview, err := PreviewFile(stderrFile, linesToPreview)
-		if err == nil {
-			if errPreview != "" {
+		if errPreviewErr == nil {
+			if len(errPreview.HeadLines) > 0 {
 				fmt.Fprintf(os.Stderr, "\nContent of %s's stderr log:\n%s\n",
-					name, errPreview)
+					name, errPreview.String())
 			}
 		} else {
 			log.WithFields(log.Fields{
 				"process name": name,
-				"err":          err,
+				"err":          errPreviewErr,
 				"command":      cmdString,
 				"command file": cmdFile,
 				"stderr file":  stderrFile,
@@ -306,6 +387,8 @@ func ExecuteTimeout(name, cmdString, cmdFile, stdoutFile, stderrFile,
 	if err := cmd.Start(); err != nil {
 		// Start failed
 		log.WithFields(log.Fields{
+			"event":        EventProcessStartFailed,
+			"err":          err,
 			"process name": name,
 			"command":      cmdString,
 			"command file": cmdFile,
@@ -313,8 +396,8 @@ func ExecuteTimeout(name, cmdString, cmdFile, stdoutFile, stderrFile,
 			"stderr file":  stderrFile,
 		}).Error(fmt.Sprintf("Could not start %s subprocess",
 			subprocessType))
-		onstart <- CmdFinishedMsg{name, FAILURE}
-		onexit <- CmdFinishedMsg{name, FAILURE}
+		onstart <- CmdFinishedMsg{Name: name, State: FAILURE, Err: err}
+		onexit <- CmdFinishedMsg{Name: name, State: FAILURE, Err: err}
 		return
 	}
 
@@ -324,39 +407,54 @@ func ExecuteTimeout(name, cmdString, cmdFile, stdoutFile, stderrFile,
 	go func() {
 		done <- cmd.Wait()
 	}()
-	onstart <- CmdFinishedMsg{name, SUCCESS}
+	onstart <- CmdFinishedMsg{Name: name, State: SUCCESS, PID: pid}
 
 	// Wait until command completion (or context timeout)
 	select {
 	case <-time.After(time.Duration(timeout) * time.Second):
-		logExecuteTimeoutError(
+		logExecuteTimeoutError(EventProcessTimeout,
 			fmt.Sprintf("%s subprocess failed (simulation timeout reached)",
-				subprocessType), nil,
+				subprocessType), nil, nil,
 			name, cmdString, cmdFile, stdoutFile, stderrFile, cmd, timeout,
 			previewOnError)
 		KillProcess(pid)
-		onexit <- CmdFinishedMsg{name, TIMEOUT}
+		onexit <- CmdFinishedMsg{Name: name, State: TIMEOUT, PID: pid}
 	case err := <-done:
 		if err != nil {
-			logExecuteTimeoutError(
+			classification := ClassifyError(newProcessFailure(name, err,
+				stdoutFile, stderrFile))
+			logExecuteTimeoutError(EventProcessFailed,
 				fmt.Sprintf("%s subprocess failed", subprocessType), err,
+				&classification,
 				name, cmdString, cmdFile, stdoutFile, stderrFile, cmd, timeout,
 				previewOnError)
 			KillProcess(pid)
-			onexit <- CmdFinishedMsg{name, FAILURE}
+			onexit <- CmdFinishedMsg{Name: name, State: FAILURE, PID: pid,
+				Err: err, Classification: &classification}
 		} else {
 			log.WithFields(log.Fields{
+				"event":        EventProcessSucceeded,
 				"process name": name,
 				"command":      cmdString,
 				"command file": cmdFile,
 				"stdout file":  stdoutFile,
 				"stderr file":  stderrFile,
 			}).Info(fmt.Sprintf("%s subprocess succeeded", subprocessType))
-			onexit <- CmdFinishedMsg{name, SUCCESS}
+			onexit <- CmdFinishedMsg{Name: name, State: SUCCESS, PID: pid}
 		}
 	}
 }
 
+// Maximum time to wait for the output of a finished process to be copied.
+// Outputs that are not directly written in files are copied through pipes,
+// that may be kept open by orphaned subprocesses.
+const outputCopyWaitDelay = 1 * time.Second
+
+// Whether the processes' outputs are copied through pipes
+func hasCopiedOutputs(exp Experiment, opts ExecuteOptions) bool {
+	return opts.Tee || exp.LogMaxSize > 0
+}
+
 // "Batsim" <-> "Scheduler"
 func oppName(str string) string {
 	if str == "Batsim" {
@@ -376,27 +474,110 @@ func cleanupSubprocesses(pidsToKill map[string]int) {
 	}
 }
 
-func setupGuards(pidsToKill *map[string]int, onAbort chan int) {
-	// Guard against ctrl+c
-	// Does not seem needed, as SIGTERM is also launched in this case
-
-	// Guard against (polite) kill
+// Kills the subprocesses then notifies onAbort (which must be buffered) if
+// robin is interrupted or (politely) killed.
+// The returned function removes the guard. It must be called once the
+// execution is over, as robin may be executed in a process that keeps
+// running (e.g. by RunRobinInProcess).
+func setupGuards(pidsToKill *map[string]int, onAbort chan int) (stop func()) {
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan bool)
+	finished := make(chan bool)
 	go func() {
-		<-sigterm
-		log.Warn("SIGTERM received. Killing remaining subprocesses.")
-		cleanupSubprocesses(*pidsToKill)
-		onAbort <- 3
+		defer close(finished)
+		select {
+		case <-sigterm:
+			log.Warn("SIGTERM received. Killing remaining subprocesses.")
+			cleanupSubprocesses(*pidsToKill)
+			onAbort <- ABORTED
+		case <-done:
+		}
 	}()
+
+	return func() {
+		signal.Stop(sigterm)
+		close(done)
+		<-finished
+	}
+}
+
+// Starts the stall watchdog if it is enabled.
+// The returned channels are used to stop the watchdog and to be notified of
+// a stall.
+func startStallWatchdog(exp Experiment, batargs BatsimArgs,
+	pidsToKill map[string]int) (stop chan bool, onstall chan string) {
+	stop = make(chan bool)
+	onstall = make(chan string, 1)
+
+	if exp.StallTimeout > 0 {
+		pgids := make([]int, 0, len(pidsToKill))
+		for _, pid := range pidsToKill {
+			pgids = append(pgids, pid)
+		}
+		go watchStall(exp, batargs, pgids, victimNames(pidsToKill), stop,
+			onstall)
+	}
+
+	return stop, onstall
+}
+
+// Returns when the total timeout of an execution starting now is reached,
+// or zero if there is no total timeout
+func totalDeadline(exp Experiment) time.Time {
+	if exp.TotalTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(exp.TotalTimeout *
+		float64(time.Second)))
+}
+
+// Returns a channel notified when the total timeout is reached.
+// The channel is nil (never notified) if there is no total timeout.
+func (record *executionRecord) totalTimeoutReached() <-chan time.Time {
+	if record.deadline.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(record.deadline))
+}
+
+// Returns the names of the processes that are about to be killed
+func victimNames(pidsToKill map[string]int) string {
+	names := make([]string, 0, len(pidsToKill))
+	for name := range pidsToKill {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func logTotalTimeoutReached(exp Experiment, phase, victims string) {
+	log.WithFields(log.Fields{
+		"event":                   EventTotalTimeout,
+		"total timeout (seconds)": exp.TotalTimeout,
+		"phase":                   phase,
+		"victim names":            victims,
+	}).Error("Total timeout reached")
+}
+
+// Records that the execution is stopped by the total timeout
+func (record *executionRecord) stopOnTotalTimeout(exp Experiment,
+	victims string) {
+	record.totalTimeout = true
+	logTotalTimeoutReached(exp, record.timeline.LastPhase(), victims)
 }
 
-func executeBatsimAlone(exp Experiment, previewOnError bool) int {
+func executeBatsimAlone(exp Experiment, batargs BatsimArgs,
+	opts ExecuteOptions, record *executionRecord) int {
+	timeline := record.timeline
+	timeline.StartPhase("start")
 	log.WithFields(log.Fields{
+		"event":                        EventSimulationStart,
 		"simulation timeout (seconds)": exp.SimulationTimeout,
 		"batsim command":               exp.Batcmd,
 		"batsim cmdfile":               exp.OutputDir + "/cmd/batsim.bash",
-		"batsim logfile":               exp.OutputDir + "/log/batsim.log",
+		"batsim logfile":               exp.LogDir() + "/batsim.log",
 	}).Info("Starting simulation")
 
 	// Create command
@@ -408,18 +589,25 @@ func executeBatsimAlone(exp Experiment, previewOnError bool) int {
 	// Create files
 	createBatsimCmdErr := ioutil.WriteFile(exp.OutputDir+"/cmd/batsim.bash",
 		[]byte(exp.Batcmd), 0755)
-	batlog, createBatsimLogErr := os.Create(exp.OutputDir + "/log/batsim.log")
+	batlog, createBatsimLogErr := createProcessLog(exp,
+		exp.LogDir()+"/batsim.log")
 
 	if createBatsimLogErr == nil {
 		defer batlog.Close()
-		cmd.Stderr = batlog
+		var flush func()
+		cmd.Stderr, flush = processOutputWriter(batlog, "Batsim", opts)
+		defer flush()
+	}
+
+	if hasCopiedOutputs(exp, opts) {
+		cmd.WaitDelay = outputCopyWaitDelay
 	}
 
 	if (createBatsimCmdErr != nil) || (createBatsimLogErr != nil) {
 		log.WithFields(log.Fields{
 			"batsim cmdfile":     exp.OutputDir + "/cmd/batsim.bash",
 			"batsim cmdfile err": createBatsimCmdErr,
-			"batsim logfile":     exp.OutputDir + "log/batsim.log",
+			"batsim logfile":     exp.LogDir() + "/batsim.log",
 			"batsim logfile err": createBatsimLogErr,
 		}).Error("Cannot create file")
 		return 1
@@ -427,40 +615,64 @@ func executeBatsimAlone(exp Experiment, previewOnError bool) int {
 
 	// Guards against SIGINT (ctrl+c) and SIGTERM (polite kill)
 	pidsToKill := make(map[string]int)
-	abort := make(chan int)
-	setupGuards(&pidsToKill, abort)
+	abort := make(chan int, 1)
+	stopGuards := setupGuards(&pidsToKill, abort)
+	defer stopGuards()
 
 	// Execute the process
 	start := make(chan CmdFinishedMsg)
 	termination := make(chan CmdFinishedMsg)
 	go ExecuteTimeout("Batsim", exp.Batcmd, exp.OutputDir+"/cmd/batsim.bash",
-		"/dev/null", exp.OutputDir+"/log/batsim.log", "Simulation", cmd,
-		exp.SimulationTimeout, start, termination, previewOnError)
+		"/dev/null", exp.LogDir()+"/batsim.log", "Simulation", cmd,
+		exp.SimulationTimeout, start, termination, opts.PreviewOnError)
 
 	start1 := <-start
 	if start1.State == SUCCESS {
 		pidsToKill["Batsim"] = cmd.Process.Pid
 	}
 
+	timeline.StartPhase("first-exit")
+	stopWatchdog, stalled := startStallWatchdog(exp, batargs, pidsToKill)
+	defer close(stopWatchdog)
+	stopProgress := startProgressReporter(exp, opts)
+	defer stopProgress()
+	defer timeline.StartPhase("teardown")
+
 	select {
 	case finish1 := <-termination:
+		record.processFinished(finish1)
 		delete(pidsToKill, "Batsim")
 		return finish1.State
+	case <-stalled:
+		cleanupSubprocesses(pidsToKill)
+		record.processFinished(<-termination)
+		delete(pidsToKill, "Batsim")
+		return STALLED
+	case <-record.totalTimeoutReached():
+		record.stopOnTotalTimeout(exp, victimNames(pidsToKill))
+		cleanupSubprocesses(pidsToKill)
+		record.processFinished(<-termination)
+		delete(pidsToKill, "Batsim")
+		return TIMEOUT
 	case abortCode := <-abort:
 		return abortCode
 	}
 }
 
-func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
+func executeBatsimAndSched(exp Experiment, batargs BatsimArgs,
+	opts ExecuteOptions, record *executionRecord) int {
+	timeline := record.timeline
+	timeline.StartPhase("start")
 	log.WithFields(log.Fields{
+		"event":                        EventSimulationStart,
 		"simulation timeout (seconds)": exp.SimulationTimeout,
 		"batsim command":               exp.Batcmd,
 		"batsim cmdfile":               exp.OutputDir + "/cmd/batsim.bash",
-		"batsim logfile":               exp.OutputDir + "/log/batsim.log",
+		"batsim logfile":               exp.LogDir() + "/batsim.log",
 		"scheduler command":            exp.Schedcmd,
 		"scheduler cmdfile":            exp.OutputDir + "/cmd/sched.bash",
-		"scheduler logfile (out)":      exp.OutputDir + "/log/sched.out.log",
-		"scheduler logfile (err)":      exp.OutputDir + "/log/sched.err.log",
+		"scheduler logfile (out)":      exp.LogDir() + "/sched.out.log",
+		"scheduler logfile (err)":      exp.LogDir() + "/sched.err.log",
 	}).Info("Starting simulation")
 
 	// Create commands
@@ -480,27 +692,42 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 	// Create files
 	createBatsimCmdErr := ioutil.WriteFile(exp.OutputDir+"/cmd/batsim.bash",
 		[]byte(exp.Batcmd), 0755)
-	batlog, createBatsimLogErr := os.Create(exp.OutputDir + "/log/batsim.log")
+	batlog, createBatsimLogErr := createProcessLog(exp,
+		exp.LogDir()+"/batsim.log")
 	createSchedCmdErr := ioutil.WriteFile(exp.OutputDir+"/cmd/sched.bash",
 		[]byte(exp.Schedcmd), 0755)
-	schedout, createSchedLogErr := os.Create(exp.OutputDir +
-		"/log/sched.out.log")
-	schederr, createSchedErrErr := os.Create(exp.OutputDir +
-		"/log/sched.err.log")
+	schedout, createSchedLogErr := createProcessLog(exp,
+		exp.LogDir()+"/sched.out.log")
+	schederr, createSchedErrErr := createProcessLog(exp,
+		exp.LogDir()+"/sched.err.log")
 
 	if createBatsimLogErr == nil {
 		defer batlog.Close()
-		cmds["Batsim"].Stderr = batlog
+		var flush func()
+		cmds["Batsim"].Stderr, flush = processOutputWriter(batlog, "Batsim",
+			opts)
+		defer flush()
 	}
 
 	if createSchedLogErr == nil {
 		defer schedout.Close()
-		cmds["Scheduler"].Stdout = schedout
+		var flush func()
+		cmds["Scheduler"].Stdout, flush = processOutputWriter(schedout,
+			"Scheduler", opts)
+		defer flush()
 	}
 
 	if createSchedErrErr == nil {
 		defer schederr.Close()
-		cmds["Scheduler"].Stderr = schederr
+		var flush func()
+		cmds["Scheduler"].Stderr, flush = processOutputWriter(schederr,
+			"Scheduler", opts)
+		defer flush()
+	}
+
+	if hasCopiedOutputs(exp, opts) {
+		cmds["Batsim"].WaitDelay = outputCopyWaitDelay
+		cmds["Scheduler"].WaitDelay = outputCopyWaitDelay
 	}
 
 	if (createBatsimCmdErr != nil) || (createSchedCmdErr != nil) ||
@@ -509,13 +736,13 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 		log.WithFields(log.Fields{
 			"batsim cmdfile":              exp.OutputDir + "/cmd/batsim.bash",
 			"batsim cmdfile err":          createBatsimCmdErr,
-			"batsim logfile":              exp.OutputDir + "log/batsim.log",
+			"batsim logfile":              exp.LogDir() + "/batsim.log",
 			"batsim logfile err":          createBatsimLogErr,
 			"scheduler cmdfile":           exp.OutputDir + "/cmd/sched.bash",
 			"scheduler cmdfile err":       createSchedCmdErr,
-			"scheduler logfile (out)":     exp.OutputDir + "/log/sched.out.log",
+			"scheduler logfile (out)":     exp.LogDir() + "/sched.out.log",
 			"scheduler logfile (out) err": createSchedLogErr,
-			"scheduler logfile (err)":     exp.OutputDir + "/log/sched.err.log",
+			"scheduler logfile (err)":     exp.LogDir() + "/sched.err.log",
 			"scheduler logfile (err) err": createSchedErrErr,
 		}).Error("Cannot create file")
 		return 1
@@ -523,37 +750,84 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 
 	// Guards against SIGINT (ctrl+c) and SIGTERM (polite kill)
 	pidsToKill := make(map[string]int)
-	abort := make(chan int)
-	setupGuards(&pidsToKill, abort)
+	abort := make(chan int, 1)
+	stopGuards := setupGuards(&pidsToKill, abort)
+	defer stopGuards()
 
 	// Execute the processes
 	start := make(chan CmdFinishedMsg)
 	termination := make(chan CmdFinishedMsg)
-	go ExecuteTimeout("Batsim", exp.Batcmd, exp.OutputDir+"/cmd/batsim.bash",
-		"/dev/null", exp.OutputDir+"/log/batsim.log", "Simulation",
-		cmds["Batsim"], exp.SimulationTimeout, start, termination,
-		previewOnError)
-	go ExecuteTimeout("Scheduler", exp.Schedcmd,
-		exp.OutputDir+"/cmd/sched.bash",
-		exp.OutputDir+"/log/sched.out.log", exp.OutputDir+"/log/sched.err.log",
-		"Simulation", cmds["Scheduler"], exp.SimulationTimeout, start,
-		termination, previewOnError)
+	if isManualProcess(opts, "Batsim") {
+		go watchManualProcess("Batsim", exp.Batcmd,
+			exp.OutputDir+"/cmd/batsim.bash", batargs, exp.SimulationTimeout,
+			start, termination)
+	} else {
+		go ExecuteTimeout("Batsim", exp.Batcmd,
+			exp.OutputDir+"/cmd/batsim.bash",
+			"/dev/null", exp.LogDir()+"/batsim.log", "Simulation",
+			cmds["Batsim"], exp.SimulationTimeout, start, termination,
+			opts.PreviewOnError)
+	}
+	if isManualProcess(opts, "Scheduler") {
+		go watchManualProcess("Scheduler", exp.Schedcmd,
+			exp.OutputDir+"/cmd/sched.bash", batargs, exp.SimulationTimeout,
+			start, termination)
+	} else {
+		go ExecuteTimeout("Scheduler", exp.Schedcmd,
+			exp.OutputDir+"/cmd/sched.bash",
+			exp.LogDir()+"/sched.out.log", exp.LogDir()+"/sched.err.log",
+			"Simulation", cmds["Scheduler"], exp.SimulationTimeout, start,
+			termination, opts.PreviewOnError)
+	}
 
 	// Wait for both to start (or to fail starting)
 	nbStartedOrFailedStarting := 0
 	for nbStartedOrFailedStarting < 2 {
 		select {
 		case start1 := <-start:
-			if start1.State == SUCCESS {
-				pidsToKill[start1.Name] = cmds[start1.Name].Process.Pid
+			// Manually started processes cannot be killed by robin
+			if start1.State == SUCCESS && start1.PID != 0 {
+				pidsToKill[start1.Name] = start1.PID
 			}
 			nbStartedOrFailedStarting += 1
 		}
 	}
 
-	// Wait for first process to finish
+	// Wait for first process to finish (or for the simulation to stall)
+	timeline.StartPhase("first-exit")
+	if opts.DebugProcess != "" && opts.DebugWrapper == "" &&
+		exp.StallTimeout > 0 {
+		// A debugged process may legitimately stop for a long time
+		log.Warning("Stall detection is disabled while a process is " +
+			"started manually")
+		exp.StallTimeout = 0
+	}
+	stopWatchdog, stalled := startStallWatchdog(exp, batargs, pidsToKill)
+	stopProgress := startProgressReporter(exp, opts)
+	defer stopProgress()
+	defer timeline.StartPhase("teardown")
+	totalTimeout := record.totalTimeoutReached()
+
 	var finish1, finish2 CmdFinishedMsg
-	finish1 = <-termination
+	select {
+	case finish1 = <-termination:
+		close(stopWatchdog)
+	case <-stalled:
+		close(stopWatchdog)
+		cleanupSubprocesses(pidsToKill)
+		record.processFinished(<-termination)
+		record.processFinished(<-termination)
+		return STALLED
+	case <-totalTimeout:
+		close(stopWatchdog)
+		record.stopOnTotalTimeout(exp, victimNames(pidsToKill))
+		cleanupSubprocesses(pidsToKill)
+		record.processFinished(<-termination)
+		record.processFinished(<-termination)
+		return TIMEOUT
+	}
+	record.processFinished(finish1)
+	timeline.StartPhase("second-exit")
 	delete(pidsToKill, finish1.Name)
 	success[finish1.Name] = finish1.State
 
@@ -575,13 +849,20 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 		case <-time.After(time.Duration(exp.SuccessTimeout) * time.Second):
 			// Success timeout reached
 			log.WithFields(log.Fields{
+				"event":                     EventSuccessTimeout,
 				"success timeout (seconds)": exp.SuccessTimeout,
+				"victim name":               oppName(finish1.Name),
 			}).Warn("Success timeout reached")
 
 			// Kill the other process
 			KillProcess(pidsToKill[oppName(finish1.Name)])
 			finish2 = <-termination
 		case finish2 = <-termination:
+		case <-totalTimeout:
+			record.stopOnTotalTimeout(exp, oppName(finish1.Name))
+			KillProcess(pidsToKill[oppName(finish1.Name)])
+			record.processFinished(<-termination)
+			return TIMEOUT
 		}
 	case FAILURE:
 		log.WithFields(log.Fields{
@@ -593,13 +874,20 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 		case <-time.After(time.Duration(exp.FailureTimeout) * time.Second):
 			// Failure timeout reached
 			log.WithFields(log.Fields{
+				"event":                     EventFailureTimeout,
 				"failure timeout (seconds)": exp.FailureTimeout,
+				"victim name":               oppName(finish1.Name),
 			}).Warn("Failure timeout reached")
 
 			// Kill the other process
 			KillProcess(pidsToKill[oppName(finish1.Name)])
 			finish2 = <-termination
 		case finish2 = <-termination:
+		case <-totalTimeout:
+			record.stopOnTotalTimeout(exp, oppName(finish1.Name))
+			KillProcess(pidsToKill[oppName(finish1.Name)])
+			record.processFinished(<-termination)
+			return TIMEOUT
 		}
 	case TIMEOUT:
 		// Wait second process completion
@@ -607,6 +895,7 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 	}
 
 	// Second process finished
+	record.processFinished(finish2)
 	delete(pidsToKill, finish2.Name)
 	success[finish2.Name] = finish2.State
 
@@ -620,7 +909,142 @@ func executeBatsimAndSched(exp Experiment, previewOnError bool) int {
 
 // Execute one Batsim simulation
 func ExecuteOne(exp Experiment, previewOnError bool) int {
+	return ExecuteOneWithOptions(exp, ExecuteOptions{
+		PreviewOnError: previewOnError,
+	})
+}
+
+// Execute one Batsim simulation with non-default execution options.
+// The simulation is executed again if it failed for a reason listed in
+// exp.RetryOn, at most exp.Retries times.
+func ExecuteOneWithOptions(exp Experiment, opts ExecuteOptions) int {
+	start := time.Now()
+	ret := executeWithRetries(exp, opts)
+
+	// Lets robintest check the duration of the whole execution
+	log.WithFields(log.Fields{
+		"event":              EventRobinFinished,
+		"return code":        ret,
+		"duration (seconds)": time.Since(start).Seconds(),
+	}).Info("Robin execution finished")
+	return ret
+}
+
+func executeWithRetries(exp Experiment, opts ExecuteOptions) int {
+	// The total timeout caps all the attempts and the waits between them
+	deadline := totalDeadline(exp)
+
+	err := ApplyOutputPolicy(exp, opts.DryRun)
+	if err != nil {
+		return 1
+	}
+
+	// Nothing is executed (nor retried) in dry-run mode
+	if opts.DryRun {
+		record := newExecutionRecord()
+		record.deadline = deadline
+		return executeOne(exp, opts, record)
+	}
+
+	if exp.CoreDumps {
+		restoreCoreLimit := enableCoreDumps()
+		defer restoreCoreLimit()
+	}
+
+	var attempts []AttemptResult
+	backoff := exp.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		exp.logDir = AttemptLogDir(exp.OutputDir, attempt)
+		ret, record := executeAttempt(exp, opts, deadline)
+
+		result := newRunResult(ret, record)
+		attempts = append(attempts, AttemptResult{
+			Attempt:    attempt,
+			State:      result.State,
+			ReturnCode: result.ReturnCode,
+			Duration:   result.Duration,
+			LogDir:     exp.LogDir(),
+		})
+		if exp.Retries > 0 {
+			result.Attempts = attempts
+		}
+
+		// The result cannot be written if the output directory is unusable
+		writeResult := func() {
+			if !record.timeline.HasPhase("parse-batcmd") {
+				return
+			}
+			err := WriteResult(exp.OutputDir, result)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"err":         err,
+					"result file": ResultFilename(exp.OutputDir),
+				}).Error("Cannot write result file")
+			}
+		}
+		writeResult()
+
+		if attempt > exp.Retries || !shouldRetry(exp, result) {
+			if ret == SUCCESS && record.batargs != nil {
+				WriteArtifactManifest(exp.OutputDir, *record.batargs)
+			}
+			if len(attempts) > 1 {
+				logAttempts(attempts)
+			}
+			return ret
+		}
+
+		log.WithFields(log.Fields{
+			"event":                   EventRetry,
+			"attempt":                 attempt,
+			"state":                   result.State,
+			"retries":                 exp.Retries,
+			"retry backoff (seconds)": backoff,
+		}).Warning("Simulation attempt failed. Retrying")
+
+		switch waitRetryBackoff(backoff, deadline) {
+		case ABORTED:
+			logAttempts(attempts)
+			return ABORTED
+		case TIMEOUT:
+			logTotalTimeoutReached(exp, "retry-backoff", "")
+			result.State = "total-timeout"
+			result.ReturnCode = TIMEOUT
+			writeResult()
+			logAttempts(attempts)
+			return TIMEOUT
+		}
+		backoff *= 2
+	}
+}
+
+// Executes one attempt of a simulation, then tears it down
+func executeAttempt(exp Experiment, opts ExecuteOptions,
+	deadline time.Time) (int, *executionRecord) {
+	record := newExecutionRecord()
+	record.deadline = deadline
+	timeline := record.timeline
+	ret := executeOne(exp, opts, record)
+	timeline.EndPhase()
+	timeline.log()
+
+	if exp.CoreDumps {
+		record.collectCrashes(exp)
+	}
+
+	if exp.LogCompress && timeline.HasPhase("parse-batcmd") {
+		compressLogs(exp.LogDir())
+	}
+
+	return ret, record
+}
+
+func executeOne(exp Experiment, opts ExecuteOptions,
+	record *executionRecord) int {
+	timeline := record.timeline
+
 	// Prepare execution
+	timeline.StartPhase("prepare-dirs")
 	err := PrepareDirs(exp)
 	if err != nil {
 		return 1
@@ -631,7 +1055,14 @@ func ExecuteOne(exp Experiment, previewOnError bool) int {
 		exp.Schedcmd = ""
 	}
 
+	// Persist the effective description, so that the run can be replayed
+	err = WriteDescription(exp)
+	if err != nil {
+		return 1
+	}
+
 	// Parse batsim command
+	timeline.StartPhase("parse-batcmd")
 	batargs, err := ParseBatsimCommand(exp.Batcmd)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -641,14 +1072,27 @@ func ExecuteOne(exp Experiment, previewOnError bool) int {
 		return 1
 	}
 
-	if !strings.HasPrefix(batargs.ExportPrefix, exp.OutputDir) {
-		log.WithFields(log.Fields{
-			"batsim prefix":    batargs.ExportPrefix,
-			"output directory": exp.OutputDir,
-			"batsim command":   exp.Batcmd,
-		}).Warning("Batsim export prefix mismatches output directory")
+	batcmd := exp.Batcmd
+	exp, batargs, err = enforceExportPrefix(exp, batargs)
+	if err != nil {
+		return 1
+	}
+	record.batargs = &batargs
+
+	// The persisted description contains the Batsim command really executed
+	if exp.Batcmd != batcmd {
+		err = WriteDescription(exp)
+		if err != nil {
+			return 1
+		}
 	}
 
+	err = checkDebugOptions(exp, batargs, opts)
+	if err != nil {
+		return 1
+	}
+	exp = wrapDebuggedCommand(exp, opts)
+
 	if exp.Schedcmd == "" {
 		// Only execute Batsim
 		if batargs.BatexecMode == false {
@@ -659,7 +1103,11 @@ func ExecuteOne(exp Experiment, previewOnError bool) int {
 			return 1
 		}
 
-		return executeBatsimAlone(exp, previewOnError)
+		if opts.DryRun {
+			return executeDryRun(exp, batargs, true)
+		}
+
+		return executeBatsimAlone(exp, batargs, opts, record)
 	} else {
 		// Execute Batsim and the scheduler
 		if batargs.BatexecMode == true {
@@ -671,15 +1119,25 @@ func ExecuteOne(exp Experiment, previewOnError bool) int {
 		}
 
 		// Wait for context to be ready (open sockets, batsim processes...)
-		err := waitReadyForSimulation(exp, batargs)
-		if err != nil {
+		timeline.StartPhase("wait-ready")
+		err := waitReadyForSimulation(exp, batargs, record)
+		if opts.DryRun {
+			return executeDryRun(exp, batargs, err == nil)
+		}
+		if record.totalTimeout {
+			return TIMEOUT
+		} else if err != nil {
 			return 1
 		}
 
-		return executeBatsimAndSched(exp, previewOnError)
+		return executeBatsimAndSched(exp, batargs, opts, record)
 	}
 }
 
 func KillProcess(pid int) {
+	// Processes that have not been started by robin have no PID
+	if pid <= 0 {
+		return
+	}
 	syscall.Kill(-pid, syscall.SIGTERM)
 }
@@ -0,0 +1,324 @@
+package batexpe_test
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	"github.com/Lucas-Doctorate-Project/batexpe/batexpetest"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Path of the fake Batsim built for the tests
+var fakeBatsim string
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := ioutil.TempDir("", "batexpe-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot create temporary directory:", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	fakeBatsim, err = batexpetest.BuildFakeBatsim(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// Returns an experiment that runs the fake Batsim with some options
+func fakeExperiment(t *testing.T, batsimOptions,
+	schedcmd string) batexpe.Experiment {
+	outputDir := batexpetest.OutputDir(t)
+	return batexpe.Experiment{
+		Batcmd: fmt.Sprintf("%s -e %s/out -s %s %s", fakeBatsim, outputDir,
+			batexpetest.FreeSocket(t), batsimOptions),
+		OutputDir:         outputDir,
+		Schedcmd:          schedcmd,
+		SimulationTimeout: 10,
+		ReadyTimeout:      2,
+		SuccessTimeout:    2,
+		FailureTimeout:    0,
+	}
+}
+
+func TestParseBatsimCommand(t *testing.T) {
+	batargs, err := batexpe.ParseBatsimCommand(fakeBatsim +
+		" -p platform.xml -w w1.json --workload=w2.json -e /tmp/prefix " +
+		"-s tcp://localhost:28042")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batargs.Socket != "tcp://localhost:28042" {
+		t.Errorf("Unexpected socket: %s", batargs.Socket)
+	}
+	if batargs.ExportPrefix != "/tmp/prefix" {
+		t.Errorf("Unexpected export prefix: %s", batargs.ExportPrefix)
+	}
+	if batargs.BatexecMode {
+		t.Errorf("Unexpected batexec mode")
+	}
+	if batargs.Platform != "platform.xml" {
+		t.Errorf("Unexpected platform: %s", batargs.Platform)
+	}
+	if strings.Join(batargs.Workloads, ",") != "w1.json,w2.json" {
+		t.Errorf("Unexpected workloads: %v", batargs.Workloads)
+	}
+}
+
+func TestParseBatsimCommandFailure(t *testing.T) {
+	_, err := batexpe.ParseBatsimCommand(fakeBatsim +
+		" --fake-jobs=not-a-number")
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestWaitReadyForSimulation(t *testing.T) {
+	exp := batexpe.Experiment{ReadyTimeout: 1}
+	batargs := batexpe.BatsimArgs{Socket: batexpetest.FreeSocket(t)}
+	port, _ := batexpe.PortFromBatSock(batargs.Socket)
+
+	if err := batexpe.WaitReadyForSimulation(exp, batargs); err != nil {
+		t.Errorf("Unexpected invalid context: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	if err := batexpe.WaitReadyForSimulation(exp, batargs); err == nil {
+		t.Errorf("Expected an invalid context as the socket is in use")
+	}
+}
+
+func TestExecuteOne(t *testing.T) {
+	tests := []struct {
+		name          string
+		batsimOptions string
+		schedcmd      string
+		expected      int
+	}{
+		{"batexec-success", "--batexec", "", batexpe.SUCCESS},
+		{"batexec-failure", "--batexec --fake-exit-code=3", "", batexpe.FAILURE},
+		{"batexec-crash", "--batexec --fake-crash=SEGV", "", batexpe.FAILURE},
+		{"batexec-timeout", "--batexec --fake-hang", "", batexpe.TIMEOUT},
+		{"sched-success", "--fake-open-socket", "sleep 0.2", batexpe.SUCCESS},
+		{"sched-failure", "--fake-duration=5", "false", batexpe.FAILURE},
+		{"batsim-failure", "--fake-exit-code=1", "sleep 5", batexpe.FAILURE},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp := fakeExperiment(t, test.batsimOptions, test.schedcmd)
+			exp.SimulationTimeout = 2
+
+			ret := batexpe.ExecuteOne(exp, false)
+			if ret != test.expected {
+				t.Errorf("Unexpected return code: expected %d, got %d",
+					test.expected, ret)
+			}
+
+			_, err := os.Stat(exp.OutputDir + "/out_jobs.csv")
+			if exported := err == nil; exported != (ret == batexpe.SUCCESS) {
+				t.Errorf("Unexpected export: exported=%t", exported)
+			}
+		})
+	}
+}
+
+func TestRunRobinInProcess(t *testing.T) {
+	exp := fakeExperiment(t, "--fake-hang", "sleep 30")
+	exp.SimulationTimeout = 1
+
+	result, events := batexpe.RunRobinInProcess(exp, batexpe.ExecuteOptions{})
+	if !result.Finished || result.Succeeded {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if timeout := events.KillingTimeout("Batsim"); timeout != "simulation-timeout" {
+		t.Errorf("Unexpected Batsim killing timeout: '%s'", timeout)
+	}
+	if !events.SchedPresent() {
+		t.Errorf("Unexpected absence of scheduler")
+	}
+	if _, found := events.Duration(); !found {
+		t.Errorf("Robin's duration has not been logged")
+	}
+}
+
+func TestTotalTimeoutWithRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		readyTimeout float64
+		retryBackoff float64
+		maxAttempts  int
+	}{
+		// Each attempt has an invalid context, the total timeout is reached
+		// during the third attempt or during the wait before it
+		{"across-attempts", 1, 0.5, 3},
+		// The total timeout is reached while waiting for a valid context
+		{"during-wait-ready", 10, 0, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp := fakeExperiment(t, "", "sleep 1")
+			exp.ReadyTimeout = test.readyTimeout
+			exp.TotalTimeout = 2
+			exp.Retries = 10
+			exp.RetryBackoff = test.retryBackoff
+			exp.RetryOn = []string{"context-invalid", "total-timeout"}
+
+			// The socket remains in use: the context is never valid
+			batargs, err := batexpe.ParseBatsimCommand(exp.Batcmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			port, _ := batexpe.PortFromBatSock(batargs.Socket)
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			begin := time.Now()
+			ret := batexpe.ExecuteOne(exp, false)
+			if ret != batexpe.TIMEOUT {
+				t.Errorf("Unexpected return code: expected %d, got %d",
+					batexpe.TIMEOUT, ret)
+			}
+			if duration := time.Since(begin).Seconds(); duration > 3 {
+				t.Errorf("Total timeout exceeded: execution took %fs",
+					duration)
+			}
+
+			result, err := batexpe.ReadResult(exp.OutputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.State != "total-timeout" {
+				t.Errorf("Unexpected state: %s", result.State)
+			}
+			if len(result.Attempts) < 1 ||
+				len(result.Attempts) > test.maxAttempts {
+				t.Errorf("Unexpected attempts: %+v", result.Attempts)
+			}
+		})
+	}
+}
+
+func TestExecuteOneReleasesGuards(t *testing.T) {
+	// The first execution starts the goroutines of the signal package
+	batexpe.ExecuteOne(fakeExperiment(t, "--batexec", ""), false)
+	before := runtime.NumGoroutine()
+
+	for _, schedcmd := range []string{"", "sleep 0.1", ""} {
+		batsimOptions := "--fake-duration=0.2"
+		if schedcmd == "" {
+			batsimOptions += " --batexec"
+		}
+		if ret := batexpe.ExecuteOne(fakeExperiment(t, batsimOptions, schedcmd),
+			false); ret != batexpe.SUCCESS {
+			t.Fatalf("Unexpected return code: %d", ret)
+		}
+	}
+
+	// Leaked goroutines would block forever
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(2 * time.Second); after > before &&
+		time.Now().Before(deadline); after = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after > before {
+		t.Errorf("Goroutines leaked by the executions: %d before, %d after",
+			before, after)
+	}
+}
+
+func TestExecuteOneInjectsExportPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		outputDir string
+		batcmd    string
+	}{
+		{"simple", "out", "%s --batexec"},
+		{"space", "my output", "%s --batexec"},
+		{"quote", "it's out", "%s --batexec"},
+		{"assignment", "out", "BATSIM_VAR=1 %s --batexec"},
+		{"redirection", "my output", "%s --batexec 2>/dev/null"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp := fakeExperiment(t, "", "")
+			exp.Batcmd = fmt.Sprintf(test.batcmd, fakeBatsim+" -s "+
+				batexpetest.FreeSocket(t))
+			exp.OutputDir = filepath.Join(exp.OutputDir, test.outputDir)
+			exp.ExportPrefixPolicy = batexpe.ExportPrefixInject
+
+			if ret := batexpe.ExecuteOne(exp, false); ret != batexpe.SUCCESS {
+				t.Fatalf("Unexpected return code: %d", ret)
+			}
+			if _, err := os.Stat(exp.OutputDir + "/out_jobs.csv"); err != nil {
+				t.Errorf("Batsim's export prefix is not in the output "+
+					"directory: %s", err)
+			}
+		})
+	}
+}
+
+func TestExecuteOneKeepsExportPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		option string
+		ret    int
+	}{
+		{"separate", "-e %s/my", batexpe.SUCCESS},
+		{"attached", "-e%s/my", batexpe.SUCCESS},
+		{"long", "--export=%s/my", batexpe.SUCCESS},
+		{"outside-output-dir", "-e%s.other/my", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exp := fakeExperiment(t, "", "")
+			exp.Batcmd = fmt.Sprintf("%s --batexec -s %s "+test.option,
+				fakeBatsim, batexpetest.FreeSocket(t), exp.OutputDir)
+			exp.ExportPrefixPolicy = batexpe.ExportPrefixInject
+
+			if ret := batexpe.ExecuteOne(exp, false); ret != test.ret {
+				t.Fatalf("Unexpected return code: %d", ret)
+			}
+			if test.ret != batexpe.SUCCESS {
+				return
+			}
+
+			if _, err := os.Stat(exp.OutputDir + "/my_jobs.csv"); err != nil {
+				t.Errorf("Batsim's export prefix not kept: %s", err)
+			}
+			cmdFile, err := ioutil.ReadFile(exp.OutputDir + "/cmd/batsim.bash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(cmdFile), exp.OutputDir+"/out") {
+				t.Errorf("Export prefix injected into %s", cmdFile)
+			}
+		})
+	}
+}
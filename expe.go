@@ -8,13 +8,36 @@ import (
 
 // Stores info on one Batsim simulation instance
 type Experiment struct {
-	Batcmd            string  `json:"batcmd"`
-	OutputDir         string  `json:"output-dir"`
-	Schedcmd          string  `json:"schedcmd"`
-	SimulationTimeout float64 `json:"simulation-timeout"`
-	ReadyTimeout      float64 `json:"ready-timeout"`
-	SuccessTimeout    float64 `json:"success-timeout"`
-	FailureTimeout    float64 `json:"failure-timeout"`
+	Batcmd             string   `json:"batcmd"`
+	OutputDir          string   `json:"output-dir"`
+	Schedcmd           string   `json:"schedcmd"`
+	SimulationTimeout  float64  `json:"simulation-timeout"`
+	ReadyTimeout       float64  `json:"ready-timeout"`
+	SuccessTimeout     float64  `json:"success-timeout"`
+	FailureTimeout     float64  `json:"failure-timeout"`
+	StallTimeout       float64  `json:"stall-timeout,omitempty"`
+	TotalTimeout       float64  `json:"total-timeout,omitempty"`
+	LogMaxSize         int64    `json:"log-max-size,omitempty"`
+	LogRotate          int      `json:"log-rotate,omitempty"`
+	LogCompress        bool     `json:"log-compress,omitempty"`
+	CoreDumps          bool     `json:"core-dumps,omitempty"`
+	CoreBacktrace      bool     `json:"core-backtrace,omitempty"`
+	Retries            int      `json:"retries,omitempty"`
+	RetryBackoff       float64  `json:"retry-backoff,omitempty"`
+	RetryOn            []string `json:"retry-on,omitempty"`
+	OnExistingOutput   string   `json:"on-existing-output,omitempty"`
+	ExportPrefixPolicy string   `json:"export-prefix-policy,omitempty"`
+
+	// Log directory of the current attempt (OutputDir/log if unset)
+	logDir string
+}
+
+// Returns the directory where the processes' logs are written
+func (exp Experiment) LogDir() string {
+	if exp.logDir != "" {
+		return exp.logDir
+	}
+	return exp.OutputDir + "/log"
 }
 
 func readStringFromDict(data map[string]interface{}, key string, yam string) (strRead string, err error) {
@@ -67,6 +90,133 @@ func readFloat64FromDict(data map[string]interface{}, key string, yam string) (f
 	return fltRead, nil
 }
 
+// Optional fields take their default value when they are missing
+func readOptionalStringFromDict(data map[string]interface{}, key string,
+	yam string, defaultValue string) (strRead string, err error) {
+	if _, ok := data[key]; !ok {
+		return defaultValue, nil
+	}
+
+	return readStringFromDict(data, key, yam)
+}
+
+func readOptionalFloat64FromDict(data map[string]interface{}, key string,
+	yam string, defaultValue float64) (fltRead float64, err error) {
+	if _, ok := data[key]; !ok {
+		return defaultValue, nil
+	}
+
+	return readFloat64FromDict(data, key, yam)
+}
+
+func readOptionalIntFromDict(data map[string]interface{}, key string,
+	yam string, defaultValue int) (intRead int, err error) {
+	fltRead, err := readOptionalFloat64FromDict(data, key, yam,
+		float64(defaultValue))
+	if err != nil {
+		return -1, err
+	}
+
+	if fltRead != float64(int(fltRead)) {
+		log.WithFields(log.Fields{
+			"yaml": yam,
+			"key":  key,
+			"map":  data,
+		}).Error("Invalid yaml: field is not an integer")
+		return -1, fmt.Errorf("Invalid yaml: field is not an integer")
+	}
+
+	return int(fltRead), nil
+}
+
+func readOptionalBoolFromDict(data map[string]interface{}, key string,
+	yam string, defaultValue bool) (boolRead bool, err error) {
+	val, ok := data[key]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	switch val.(type) {
+	case bool:
+		boolRead = val.(bool)
+	default:
+		log.WithFields(log.Fields{
+			"yaml": yam,
+			"key":  key,
+			"map":  data,
+		}).Error("Invalid yaml: field is not a bool")
+		return false, fmt.Errorf("Invalid yaml: field is not a bool")
+	}
+
+	return boolRead, nil
+}
+
+// Sizes are either numbers of bytes or strings such as "100M"
+func readOptionalSizeFromDict(data map[string]interface{}, key string,
+	yam string, defaultValue int64) (sizeRead int64, err error) {
+	val, ok := data[key]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	switch val.(type) {
+	case float64:
+		sizeRead = int64(val.(float64))
+	case string:
+		sizeRead, err = ParseSize(val.(string))
+	default:
+		err = fmt.Errorf("Invalid yaml: field is not a size")
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"yaml": yam,
+			"key":  key,
+			"map":  data,
+			"err":  err,
+		}).Error("Invalid yaml: field is not a size")
+		return -1, fmt.Errorf("Invalid yaml: field is not a size")
+	}
+
+	return sizeRead, nil
+}
+
+// String lists are either YAML lists or comma-separated strings
+func readOptionalStringListFromDict(data map[string]interface{}, key string,
+	yam string, defaultValue []string) (listRead []string, err error) {
+	val, ok := data[key]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	switch val.(type) {
+	case string:
+		return SplitList(val.(string)), nil
+	case []interface{}:
+		for _, item := range val.([]interface{}) {
+			str, ok := item.(string)
+			if !ok {
+				err = fmt.Errorf("Invalid yaml: field is not a string list")
+				break
+			}
+			listRead = append(listRead, str)
+		}
+	default:
+		err = fmt.Errorf("Invalid yaml: field is not a string list")
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"yaml": yam,
+			"key":  key,
+			"map":  data,
+		}).Error("Invalid yaml: field is not a string list")
+		return nil, err
+	}
+
+	return listRead, nil
+}
+
 func FromYaml(str string) (exp Experiment, convertErr error) {
 	byt := []byte(str)
 
@@ -85,7 +235,9 @@ func FromYaml(str string) (exp Experiment, convertErr error) {
 		"dict": data,
 	}).Debug("yaml -> dict")
 
-	var err1, err2, err3, err4, err5, err6, err7 error
+	var err1, err2, err3, err4, err5, err6, err7, err8, err9 error
+	var err10, err11, err12, err13, err14, err15, err16, err17, err18 error
+	var err19 error
 
 	exp.Batcmd, err1 = readStringFromDict(data, "batcmd", str)
 	exp.OutputDir, err2 = readStringFromDict(data, "output-dir", str)
@@ -97,9 +249,34 @@ func FromYaml(str string) (exp Experiment, convertErr error) {
 		str)
 	exp.FailureTimeout, err7 = readFloat64FromDict(data, "failure-timeout",
 		str)
+	exp.StallTimeout, err8 = readOptionalFloat64FromDict(data,
+		"stall-timeout", str, 0)
+	exp.TotalTimeout, err9 = readOptionalFloat64FromDict(data,
+		"total-timeout", str, 0)
+	exp.LogMaxSize, err10 = readOptionalSizeFromDict(data, "log-max-size",
+		str, 0)
+	exp.LogRotate, err11 = readOptionalIntFromDict(data, "log-rotate", str, 0)
+	exp.LogCompress, err12 = readOptionalBoolFromDict(data, "log-compress",
+		str, false)
+	exp.CoreDumps, err13 = readOptionalBoolFromDict(data, "core-dumps", str,
+		false)
+	exp.CoreBacktrace, err14 = readOptionalBoolFromDict(data,
+		"core-backtrace", str, false)
+	exp.Retries, err15 = readOptionalIntFromDict(data, "retries", str, 0)
+	exp.RetryBackoff, err16 = readOptionalFloat64FromDict(data,
+		"retry-backoff", str, 0)
+	exp.RetryOn, err17 = readOptionalStringListFromDict(data, "retry-on", str,
+		nil)
+	exp.OnExistingOutput, err18 = readOptionalStringFromDict(data,
+		"on-existing-output", str, "")
+	exp.ExportPrefixPolicy, err19 = readOptionalStringFromDict(data,
+		"export-prefix-policy", str, "")
 
 	if (err1 != nil) || (err2 != nil) || (err3 != nil) || (err4 != nil) ||
-		(err5 != nil) || (err6 != nil) || (err7 != nil) {
+		(err5 != nil) || (err6 != nil) || (err7 != nil) || (err8 != nil) ||
+		(err9 != nil) || (err10 != nil) || (err11 != nil) || (err12 != nil) ||
+		(err13 != nil) || (err14 != nil) || (err15 != nil) || (err16 != nil) ||
+		(err17 != nil) || (err18 != nil) || (err19 != nil) {
 		return exp, fmt.Errorf("Invalid yaml")
 	}
 
@@ -0,0 +1,8 @@
+package batexpe
+
+// Exposes unexported functions to the tests of package batexpe_test, which
+// can use the batexpetest helpers (batexpetest imports batexpe)
+
+func WaitReadyForSimulation(exp Experiment, batargs BatsimArgs) error {
+	return waitReadyForSimulation(exp, batargs, newExecutionRecord())
+}
@@ -0,0 +1,129 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policies applied when Batsim's export prefix is not in the output directory
+const (
+	// Only log a warning
+	ExportPrefixWarn = "warn"
+	// Refuse to run the simulation
+	ExportPrefixStrict = "strict"
+	// Inject -e <output-dir>/out into the Batsim command if it keeps Batsim's
+	// default export prefix, refuse to run otherwise
+	ExportPrefixInject = "inject"
+)
+
+// Batsim's export prefix when its command sets none
+const batsimDefaultExportPrefix = "out"
+
+// Matches the program name of a Batsim command, after its leading variable
+// assignments
+var batsimProgramRegexp = func() *regexp.Regexp {
+	word := `(?:'[^']*'|"(?:[^"\\]|\\.)*"|\\.|[^\s'"\\])+`
+	return regexp.MustCompile(`^\s*(?:\w+=` + word + `?\s+)*` + word)
+}()
+
+// Quotes a word so that bash reads it as is
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// Inserts options right after the program name of a Batsim command, so that
+// they are not affected by what ends the command (redirections, other
+// commands...). Leading variable assignments are skipped.
+func insertBatsimOptions(batcmd, options string) string {
+	program := batsimProgramRegexp.FindStringIndex(batcmd)
+	if program == nil {
+		return batcmd + " " + options
+	}
+	return batcmd[:program[1]] + " " + options + batcmd[program[1]:]
+}
+
+// Returns whether the files of an export prefix are in a directory.
+// Relative paths are relative to robin's working directory.
+func isPrefixInDir(prefix, dir string) bool {
+	absPrefix, err1 := filepath.Abs(prefix)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return strings.HasPrefix(prefix, dir)
+	}
+
+	// The prefix "dir" means files such as "dir_jobs.csv", next to dir,
+	// whereas "dir/" means files such as "dir/_jobs.csv"
+	rel, err := filepath.Rel(absDir, absPrefix)
+	if err != nil || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return rel != "." || strings.HasSuffix(prefix, "/")
+}
+
+// Applies the export-prefix policy of an experiment.
+// Returns the experiment and the Batsim arguments to use, which differ from
+// the given ones if an export prefix has been injected.
+func enforceExportPrefix(exp Experiment, batargs BatsimArgs) (Experiment,
+	BatsimArgs, error) {
+	policy := exp.ExportPrefixPolicy
+	if policy == "" {
+		policy = ExportPrefixWarn
+	}
+
+	switch policy {
+	case ExportPrefixWarn, ExportPrefixStrict, ExportPrefixInject:
+	default:
+		log.WithFields(log.Fields{
+			"policy": policy,
+		}).Error("Invalid export-prefix policy " +
+			"(expected warn, strict or inject)")
+		return exp, batargs, fmt.Errorf("Invalid export-prefix policy")
+	}
+
+	// Batsim's parsed prefix tells whether the command sets one, whatever
+	// the form of its -e option
+	if policy == ExportPrefixInject &&
+		batargs.ExportPrefix == batsimDefaultExportPrefix {
+		exp.Batcmd = insertBatsimOptions(exp.Batcmd,
+			"-e "+shellQuote(exp.OutputDir+"/out"))
+		injectedBatargs, err := ParseBatsimCommand(exp.Batcmd)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"command": exp.Batcmd,
+				"err":     err,
+			}).Error("Cannot retrieve information from Batsim command")
+			return exp, batargs, err
+		}
+		batargs = injectedBatargs
+
+		log.WithFields(log.Fields{
+			"batsim prefix":  batargs.ExportPrefix,
+			"batsim command": exp.Batcmd,
+		}).Info("Export prefix injected into Batsim command")
+	}
+
+	if !isPrefixInDir(batargs.ExportPrefix, exp.OutputDir) {
+		fields := log.Fields{
+			"batsim prefix":    batargs.ExportPrefix,
+			"output directory": exp.OutputDir,
+			"batsim command":   exp.Batcmd,
+			"policy":           policy,
+		}
+
+		if policy == ExportPrefixWarn {
+			log.WithFields(fields).Warning(
+				"Batsim export prefix mismatches output directory")
+		} else {
+			log.WithFields(fields).Error(
+				"Batsim export prefix mismatches output directory")
+			return exp, batargs, fmt.Errorf(
+				"Batsim export prefix mismatches output directory")
+		}
+	}
+
+	return exp, batargs, nil
+}
@@ -0,0 +1,32 @@
+package batexpe
+
+import "testing"
+
+func TestInsertBatsimOptions(t *testing.T) {
+	tests := []struct {
+		batcmd   string
+		expected string
+	}{
+		{"batsim", "batsim -e 'out'"},
+		{"batsim -p p.xml", "batsim -e 'out' -p p.xml"},
+		{"  batsim -p p.xml", "  batsim -e 'out' -p p.xml"},
+		{"batsim -p p.xml 2>&1 > batsim.out",
+			"batsim -e 'out' -p p.xml 2>&1 > batsim.out"},
+		{"batsim -p p.xml; echo done", "batsim -e 'out' -p p.xml; echo done"},
+		{"A=1 B='x y' batsim -p p.xml", "A=1 B='x y' batsim -e 'out' -p p.xml"},
+		{"'/opt/my batsim/batsim' -p p.xml",
+			"'/opt/my batsim/batsim' -e 'out' -p p.xml"},
+		{`"/opt/my batsim/batsim" -p p.xml`,
+			`"/opt/my batsim/batsim" -e 'out' -p p.xml`},
+		{`/opt/my\ batsim/batsim -p p.xml`,
+			`/opt/my\ batsim/batsim -e 'out' -p p.xml`},
+	}
+
+	for _, test := range tests {
+		if got := insertBatsimOptions(test.batcmd, "-e 'out'"); got !=
+			test.expected {
+			t.Errorf("Unexpected command: expected \"%s\", got \"%s\"",
+				test.expected, got)
+		}
+	}
+}
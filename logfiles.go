@@ -0,0 +1,204 @@
+package batexpe
+
+import (
+	"compress/gzip"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Parses a size in bytes, that may use a K, M or G (powers of 1024) suffix
+func ParseSize(str string) (size int64, err error) {
+	r := regexp.MustCompile(`^\s*(\d+)\s*([kKmMgG]?)[bB]?\s*$`)
+	capture := r.FindStringSubmatch(str)
+	if capture == nil {
+		return 0, fmt.Errorf("Invalid size '%s'", str)
+	}
+
+	size, err = strconv.ParseInt(capture[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid size '%s'", str)
+	}
+
+	switch strings.ToUpper(capture[2]) {
+	case "K":
+		size *= 1 << 10
+	case "M":
+		size *= 1 << 20
+	case "G":
+		size *= 1 << 30
+	}
+	return size, nil
+}
+
+// A log file whose size is capped.
+// When the cap is reached, the file is either truncated (keep = 0) or
+// rotated: filename is moved to filename.1, filename.1 to filename.2...
+// and at most keep rotated files are kept.
+type cappedLogFile struct {
+	filename string
+	maxSize  int64
+	keep     int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// Writes p, rotating the file when the cap is reached.
+// Data that does not fit in a fresh file is split, so that the cap always
+// holds.
+func (logFile *cappedLogFile) Write(p []byte) (int, error) {
+	logFile.mutex.Lock()
+	defer logFile.mutex.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		if logFile.size > 0 && logFile.size+int64(len(p)) > logFile.maxSize {
+			if err := logFile.rotate(); err != nil {
+				return written, err
+			}
+		}
+
+		chunk := p
+		if free := logFile.maxSize - logFile.size; int64(len(chunk)) > free {
+			chunk = chunk[:free]
+		}
+
+		n, err := logFile.file.Write(chunk)
+		logFile.size += int64(n)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (logFile *cappedLogFile) rotate() error {
+	if logFile.keep <= 0 {
+		if err := logFile.file.Truncate(0); err != nil {
+			return err
+		}
+		_, err := logFile.file.Seek(0, io.SeekStart)
+		logFile.size = 0
+		return err
+	}
+
+	logFile.file.Close()
+	for i := logFile.keep - 1; i >= 1; i-- {
+		older := fmt.Sprintf("%s.%d", logFile.filename, i)
+		if _, err := os.Stat(older); err == nil {
+			os.Rename(older, fmt.Sprintf("%s.%d", logFile.filename, i+1))
+		}
+	}
+	if err := os.Rename(logFile.filename, logFile.filename+".1"); err != nil {
+		return err
+	}
+
+	file, err := os.Create(logFile.filename)
+	if err != nil {
+		return err
+	}
+	logFile.file = file
+	logFile.size = 0
+	return nil
+}
+
+func (logFile *cappedLogFile) Close() error {
+	logFile.mutex.Lock()
+	defer logFile.mutex.Unlock()
+	return logFile.file.Close()
+}
+
+// Creates the log file of a process, capping its size if requested in exp.
+// Rotated files from a previous execution are removed.
+func createProcessLog(exp Experiment, filename string) (io.WriteCloser, error) {
+	for _, rotated := range RotatedLogFiles(filename) {
+		os.Remove(rotated)
+	}
+	os.Remove(filename + ".gz")
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if exp.LogMaxSize <= 0 {
+		return file, nil
+	}
+
+	return &cappedLogFile{
+		filename: filename,
+		maxSize:  exp.LogMaxSize,
+		keep:     exp.LogRotate,
+		file:     file,
+	}, nil
+}
+
+// Returns the existing rotated files of a log, from the most recent to the
+// oldest one. Compressed rotated files are included.
+func RotatedLogFiles(filename string) []string {
+	var rotated []string
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d", filename, i)
+		if _, err := os.Stat(candidate); err == nil {
+			rotated = append(rotated, candidate)
+		} else if _, err := os.Stat(candidate + ".gz"); err == nil {
+			rotated = append(rotated, candidate+".gz")
+		} else {
+			return rotated
+		}
+	}
+}
+
+// Compresses a file into filename.gz, then removes filename
+func gzipFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(filename + ".gz")
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(out)
+	_, copyErr := io.Copy(writer, in)
+	closeErr := writer.Close()
+	outCloseErr := out.Close()
+
+	if copyErr != nil || closeErr != nil || outCloseErr != nil {
+		os.Remove(filename + ".gz")
+		return fmt.Errorf("Cannot compress '%s'", filename)
+	}
+
+	return os.Remove(filename)
+}
+
+// Compresses all the (non-compressed) files of a log directory
+func compressLogs(logDir string) {
+	filenames, _ := filepath.Glob(logDir + "/*")
+	for _, filename := range filenames {
+		info, err := os.Stat(filename)
+		if err != nil || info.IsDir() || strings.HasSuffix(filename, ".gz") {
+			continue
+		}
+
+		if err := gzipFile(filename); err != nil {
+			log.WithFields(log.Fields{
+				"err":      err,
+				"filename": filename,
+			}).Error("Cannot compress log file")
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package batexpe
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCappedLogFileOversizedWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		keep     int
+		writes   []string
+		expected []string // Live file, then rotated files from the most recent
+	}{
+		{"truncate-first-write", 5, 0, []string{"0123456789abc"},
+			[]string{"abc"}},
+		{"truncate-after-write", 5, 0, []string{"xy\n", "0123456789abc"},
+			[]string{"abc"}},
+		{"rotate-first-write", 4, 2, []string{"0123456789abc"},
+			[]string{"c", "89ab", "4567"}},
+		{"rotate-after-write", 4, 5, []string{"xy\n", "0123456789abc"},
+			[]string{"c", "89ab", "4567", "0123", "xy\n"}},
+		{"rotate-whole-writes", 4, 5, []string{"xy\n", "z\n", "0123"},
+			[]string{"0123", "z\n", "xy\n"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "batsim.log")
+			logFile, err := createProcessLog(Experiment{
+				LogMaxSize: test.maxSize,
+				LogRotate:  test.keep,
+			}, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, write := range test.writes {
+				n, err := logFile.Write([]byte(write))
+				if err != nil || n != len(write) {
+					t.Fatalf("Unexpected write result: n=%d, err=%v", n, err)
+				}
+			}
+			logFile.Close()
+
+			files := append([]string{filename}, RotatedLogFiles(filename)...)
+			var contents []string
+			for _, file := range files {
+				byt, err := ioutil.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(byt)) > test.maxSize {
+					t.Errorf("%s exceeds the cap: %d bytes", file, len(byt))
+				}
+				contents = append(contents, string(byt))
+			}
+
+			if strings.Join(contents, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("Unexpected log files: expected %q, got %q",
+					test.expected, contents)
+			}
+		})
+	}
+}
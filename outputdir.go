@@ -0,0 +1,171 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Policies applied when the output directory already exists
+const (
+	OutputPolicyFail      = "fail"
+	OutputPolicyOverwrite = "overwrite"
+	OutputPolicyArchive   = "archive"
+	OutputPolicyReuse     = "reuse"
+)
+
+const DefaultOutputPolicy = OutputPolicyReuse
+
+// Returns whether a directory exists and contains files
+func isNonEmptyDir(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	return err == nil && len(files) > 0
+}
+
+// Returns the files and directories robin creates in an output directory.
+// These are the only ones removed by the overwrite policy.
+func robinOutputFiles(outputDir string) []string {
+	return []string{
+		outputDir + "/cmd",
+		outputDir + "/log",
+		CrashDir(outputDir),
+		ResultFilename(outputDir),
+		DescriptionFilename(outputDir),
+		ArtifactsFilename(outputDir),
+	}
+}
+
+// Returns whether a directory is dir itself or one of its ancestors
+func isSameOrAncestorDir(ancestor, dir string) bool {
+	rel, err := filepath.Rel(ancestor, dir)
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Returns the absolute path of a directory, its symbolic links resolved
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// Checks that the overwrite policy may clean an output directory.
+// The directory must have been written by robin, and must be neither the
+// working directory, the home directory nor one of their ancestors.
+func checkOutputDirCleanable(outputDir string) error {
+	dir, err := resolveDir(outputDir)
+	if err != nil {
+		return err
+	}
+
+	protected := []string{}
+	if cwd, err := os.Getwd(); err == nil {
+		protected = append(protected, cwd)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		protected = append(protected, home)
+	}
+	for _, protectedDir := range protected {
+		if resolved, err := resolveDir(protectedDir); err == nil {
+			protectedDir = resolved
+		}
+		if isSameOrAncestorDir(dir, protectedDir) {
+			return fmt.Errorf("%s contains %s", outputDir, protectedDir)
+		}
+	}
+
+	markers := []string{
+		ResultFilename(outputDir),
+		outputDir + "/cmd/batsim.bash",
+	}
+	for _, marker := range markers {
+		if _, err := os.Stat(marker); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has not been written by robin", outputDir)
+}
+
+// Returns the directory an output directory would be archived to
+func OutputArchiveDir(outputDir string, date time.Time) string {
+	return filepath.Clean(outputDir) + "." + date.Format("20060102-150405")
+}
+
+// Applies the on-existing-output policy of an experiment.
+// In dry-run mode, the action is only logged.
+func ApplyOutputPolicy(exp Experiment, dryRun bool) error {
+	policy := exp.OnExistingOutput
+	if policy == "" {
+		policy = DefaultOutputPolicy
+	}
+
+	switch policy {
+	case OutputPolicyFail, OutputPolicyOverwrite, OutputPolicyArchive,
+		OutputPolicyReuse:
+	default:
+		log.WithFields(log.Fields{
+			"policy": policy,
+		}).Error("Invalid on-existing-output policy " +
+			"(expected fail, overwrite, archive or reuse)")
+		return fmt.Errorf("Invalid on-existing-output policy")
+	}
+
+	if !isNonEmptyDir(exp.OutputDir) {
+		return nil
+	}
+
+	fields := log.Fields{
+		"output directory": exp.OutputDir,
+		"policy":           policy,
+		"dry run":          dryRun,
+	}
+
+	switch policy {
+	case OutputPolicyFail:
+		log.WithFields(fields).Error("Output directory already exists")
+		return fmt.Errorf("Output directory already exists")
+	case OutputPolicyOverwrite:
+		if err := checkOutputDirCleanable(exp.OutputDir); err != nil {
+			fields["err"] = err
+			log.WithFields(fields).Error("Refusing to clean output directory")
+			return fmt.Errorf("Refusing to clean output directory")
+		}
+
+		if !dryRun {
+			for _, file := range robinOutputFiles(exp.OutputDir) {
+				if err := os.RemoveAll(file); err != nil {
+					fields["err"] = err
+					log.WithFields(fields).Error("Cannot clean output directory")
+					return fmt.Errorf("Cannot clean output directory")
+				}
+			}
+		}
+		log.WithFields(fields).Info("Existing output directory cleaned")
+	case OutputPolicyArchive:
+		archiveDir := OutputArchiveDir(exp.OutputDir, time.Now())
+		fields["archive directory"] = archiveDir
+		if _, err := os.Stat(archiveDir); err == nil {
+			log.WithFields(fields).Error("Archive directory already exists")
+			return fmt.Errorf("Archive directory already exists")
+		}
+
+		if !dryRun {
+			if err := os.Rename(exp.OutputDir, archiveDir); err != nil {
+				fields["err"] = err
+				log.WithFields(fields).Error("Cannot archive output directory")
+				return fmt.Errorf("Cannot archive output directory")
+			}
+		}
+		log.WithFields(fields).Info("Existing output directory archived")
+	case OutputPolicyReuse:
+		log.WithFields(fields).Info("Existing output directory reused")
+	}
+
+	return nil
+}
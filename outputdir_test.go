@@ -0,0 +1,116 @@
+package batexpe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates the files a previous robin run leaves in an output directory
+func writePreviousRun(t *testing.T, outputDir string) {
+	for _, dir := range []string{"cmd", "log", "crash"} {
+		if err := os.MkdirAll(filepath.Join(outputDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"cmd/batsim.bash", "log/batsim.log",
+		"result.json", "description.yaml", "artifacts.json"} {
+		err := ioutil.WriteFile(filepath.Join(outputDir, file), []byte("{}"),
+			0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func overwrittenExperiment(outputDir string) Experiment {
+	return Experiment{
+		OutputDir:        outputDir,
+		OnExistingOutput: OutputPolicyOverwrite,
+	}
+}
+
+func TestApplyOutputPolicyOverwrite(t *testing.T) {
+	outputDir := t.TempDir()
+	writePreviousRun(t, outputDir)
+	userFile := filepath.Join(outputDir, "notes.txt")
+	if err := ioutil.WriteFile(userFile, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyOutputPolicy(overwrittenExperiment(outputDir),
+		true); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range robinOutputFiles(outputDir) {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("%s removed in dry-run mode", file)
+		}
+	}
+
+	if err := ApplyOutputPolicy(overwrittenExperiment(outputDir),
+		false); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range robinOutputFiles(outputDir) {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", file)
+		}
+	}
+	if _, err := os.Stat(userFile); err != nil {
+		t.Errorf("File not written by robin removed: %s", err)
+	}
+}
+
+func TestApplyOutputPolicyOverwriteRefused(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home", "user")
+	cwd := filepath.Join(root, "work", "experiments")
+	for _, dir := range []string{home, cwd} {
+		writePreviousRun(t, dir)
+	}
+	t.Setenv("HOME", home)
+	t.Chdir(cwd)
+
+	notRobinDir := filepath.Join(root, "data")
+	if err := os.MkdirAll(notRobinDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dataFile := filepath.Join(notRobinDir, "log")
+	if err := ioutil.WriteFile(dataFile, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writePreviousRun(t, filepath.Join(root, "work"))
+
+	tests := []struct {
+		name      string
+		outputDir string
+	}{
+		{"working-directory", "."},
+		{"working-directory-absolute", cwd},
+		{"working-directory-parent", ".."},
+		{"working-directory-ancestor", "../.."},
+		{"root", "/"},
+		{"home", home},
+		{"home-parent", filepath.Join(home, "..")},
+		{"without-robin-files", notRobinDir},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ApplyOutputPolicy(overwrittenExperiment(test.outputDir),
+				false)
+			if err == nil {
+				t.Fatalf("Output directory %s cleaned", test.outputDir)
+			}
+		})
+	}
+
+	for _, file := range []string{ResultFilename(home), ResultFilename(cwd),
+		ResultFilename(filepath.Join(root, "work")), dataFile} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("%s removed: %s", file, err)
+		}
+	}
+}
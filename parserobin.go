@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -15,31 +16,76 @@ import (
 type RobinResult struct {
 	Finished  bool
 	Succeeded bool
-	Output    string
+	// Return code of robin, meaningful if Finished
+	ReturnCode int
+	Output     string
+	// Wall-clock duration of the robin process, in seconds
+	Duration float64
+}
+
+// How robintest-like programs execute robin
+type RunRobinOptions struct {
+	// robin binary to execute. If empty, robin (or robin.cover if CoverFile
+	// is set) is searched in the PATH.
+	Binary string
+	// Arguments given to robin after the description file
+	ExtraArgs []string
+	// Environment variables (KEY=VALUE) added to robin's environment
+	Env []string
+	// If set, robin is a coverage-enabled binary that writes its coverage
+	// in this file
+	CoverFile string
+	// robin is killed if it runs longer than this (in seconds)
+	Timeout float64
 }
 
 func RunRobin(descriptionFile, coverFile string,
 	testTimeout float64) RobinResult {
+	return RunRobinWithOptions(descriptionFile, RunRobinOptions{
+		CoverFile: coverFile,
+		Timeout:   testTimeout,
+	})
+}
+
+// Executes robin on a description file, with its logs in JSON
+func RunRobinWithOptions(descriptionFile string,
+	opts RunRobinOptions) RobinResult {
 	termination := make(chan RobinResult)
-	go executeRobinWithTimeout(testTimeout, descriptionFile, coverFile,
-		termination)
+	go executeRobinWithTimeout(descriptionFile, opts, termination)
 
 	rresult := <-termination
 	return rresult
 }
 
-func executeRobinWithTimeout(timeout float64, descriptionFile,
-	coverFile string, onexit chan RobinResult) {
-	cmd := exec.Command("robin")
-
-	if coverFile == "" {
-		cmd.Args = []string{cmd.Args[0], "--json-logs", descriptionFile}
+// Returns the command that executes robin
+func robinCommand(descriptionFile string, opts RunRobinOptions) *exec.Cmd {
+	binary := opts.Binary
+	var args []string
+	if opts.CoverFile == "" {
+		if binary == "" {
+			binary = "robin"
+		}
+		args = []string{"--json-logs", descriptionFile}
 	} else {
-		testArg := "-test.coverprofile=" + coverFile
-		cmd = exec.Command("robin.cover")
-		cmd.Args = []string{cmd.Args[0], testArg, descriptionFile,
-			"--json-logs"}
+		if binary == "" {
+			binary = "robin.cover"
+		}
+		testArg := "-test.coverprofile=" + opts.CoverFile
+		args = []string{testArg, descriptionFile, "--json-logs"}
+	}
+
+	cmd := exec.Command(binary, append(args, opts.ExtraArgs...)...)
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
 	}
+	return cmd
+}
+
+func executeRobinWithTimeout(descriptionFile string, opts RunRobinOptions,
+	onexit chan RobinResult) {
+	timeout := opts.Timeout
+	coverFile := opts.CoverFile
+	cmd := robinCommand(descriptionFile, opts)
 
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
@@ -62,6 +108,7 @@ func executeRobinWithTimeout(timeout float64, descriptionFile,
 	}
 
 	robinPid := cmd.Process.Pid
+	start := time.Now()
 	done := make(chan error, 1)
 	go func() {
 		done <- cmd.Wait()
@@ -79,13 +126,16 @@ func executeRobinWithTimeout(timeout float64, descriptionFile,
 		KillProcess(robinPid)
 		<-done
 		rresult.Output = stdout.String()
+		rresult.Duration = time.Since(start).Seconds()
 	case <-done:
 		rresult.Output = stdout.String()
+		rresult.Duration = time.Since(start).Seconds()
 		rresult.Finished = true
 
 		if coverFile == "" {
 			// robin is directly executed, its return code can be retrieved
 			rresult.Succeeded = cmd.ProcessState.Success()
+			rresult.ReturnCode = cmd.ProcessState.ExitCode()
 
 			log.WithFields(log.Fields{
 				"succeeded": rresult.Succeeded,
@@ -108,6 +158,7 @@ func executeRobinWithTimeout(timeout float64, descriptionFile,
 				"returnCode": returnCode,
 			}).Debug("Retrieved robin return code")
 			rresult.Succeeded = returnCode == 0
+			rresult.ReturnCode = returnCode
 		}
 	}
 	onexit <- rresult
@@ -163,62 +214,19 @@ func retrieveRobinReturnCodeInRobincoverOutput(output string) (int, error) {
 	return int(returnCode), nil
 }
 
+// Deprecated: use RobinEvents.ProcessOutcome
 func WasBatsimSuccessful(robinJsonLines []interface{}) (successful, killed bool) {
-	for _, object := range robinJsonLines {
-		lineAsMap := object.(map[string]interface{})
-
-		if lineAsMap["msg"] == "Simulation subprocess succeeded" &&
-			lineAsMap["process name"] == "Batsim" {
-			return true, false
-		} else if lineAsMap["msg"] == "Simulation subprocess failed" &&
-			lineAsMap["process name"] == "Batsim" {
-			batsimKilled := strings.HasPrefix(lineAsMap["err"].(string),
-				"signal: ")
-			return false, batsimKilled
-		} else if lineAsMap["msg"] == "Simulation subprocess failed (simulation timeout reached)" &&
-			lineAsMap["process name"] == "Batsim" {
-			return false, true
-		}
-	}
-
-	return false, false
+	return NewRobinEvents(robinJsonLines).ProcessOutcome("Batsim")
 }
 
+// Deprecated: use RobinEvents.ProcessOutcome and RobinEvents.SchedPresent
 func WasSchedSuccessful(robinJsonLines []interface{}) (successful, present, killed bool) {
-	present = false
-	for _, object := range robinJsonLines {
-		lineAsMap := object.(map[string]interface{})
-
-		if lineAsMap["msg"] == "Starting simulation" {
-			_, sched_in_simu := lineAsMap["scheduler command"]
-			present = sched_in_simu
-		} else if lineAsMap["msg"] == "Simulation subprocess succeeded" &&
-			lineAsMap["process name"] == "Scheduler" {
-			return true, present, false
-		} else if lineAsMap["msg"] == "Simulation subprocess failed" &&
-			lineAsMap["process name"] == "Scheduler" {
-			schedKilled := strings.HasPrefix(lineAsMap["err"].(string),
-				"signal: ")
-			return false, present, schedKilled
-		} else if lineAsMap["msg"] == "Simulation subprocess failed (simulation timeout reached)" &&
-			lineAsMap["process name"] == "Scheduler" {
-			return false, present, true
-		}
-	}
-
-	return false, present, false
+	events := NewRobinEvents(robinJsonLines)
+	successful, killed = events.ProcessOutcome("Scheduler")
+	return successful, events.SchedPresent(), killed
 }
 
+// Deprecated: use RobinEvents.ContextClean
 func WasContextClean(robinJsonLines []interface{}) bool {
-	for _, object := range robinJsonLines {
-		lineAsMap := object.(map[string]interface{})
-
-		if lineAsMap["msg"] == "Starting simulation" {
-			return true
-		} else if lineAsMap["msg"] == "Context remains invalid" {
-			return false
-		}
-	}
-
-	return true
+	return NewRobinEvents(robinJsonLines).ContextClean()
 }
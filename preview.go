@@ -0,0 +1,313 @@
+package batexpe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Preview of a file content: its first and last lines.
+// If the file is short enough, all its lines are in HeadLines and TailLines
+// is empty.
+type FilePreview struct {
+	Filename     string   `json:"filename"`
+	HeadLines    []string `json:"head-lines"`
+	TailLines    []string `json:"tail-lines"`
+	TotalLines   int64    `json:"total-lines"`
+	Truncated    bool     `json:"truncated"`
+	RotatedFiles []string `json:"rotated-files,omitempty"`
+}
+
+// Previewed lines longer than this are cut (binary files may have huge lines)
+const previewMaxLineLength = 1024
+
+// Size of the blocks read when seeking lines from the end of a file
+const previewBlockSize = 64 * 1024
+
+// Makes a line printable: invalid UTF-8 and control characters are replaced
+func sanitizePreviewLine(line []byte) string {
+	cut := false
+	if len(line) > previewMaxLineLength {
+		line = line[:previewMaxLineLength]
+		cut = true
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '\t' || !unicode.IsControl(r) {
+			return r
+		}
+		return unicode.ReplacementChar
+	}, strings.ToValidUTF8(strings.TrimSuffix(string(line), "\r"), "�"))
+
+	if cut {
+		sanitized += " [...]"
+	}
+	return sanitized
+}
+
+// Counts the lines of a stream. A last line without trailing newline counts.
+func countLines(reader io.Reader) (int64, error) {
+	var count int64
+	endsWithNewline := true
+	buf := make([]byte, previewBlockSize)
+
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			endsWithNewline = buf[n-1] == '\n'
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if !endsWithNewline {
+		count += 1
+	}
+	return count, nil
+}
+
+// Reads the first lines of a stream
+func readHeadLines(reader io.Reader, nbLines int64) ([]string, error) {
+	lines := make([]string, 0, nbLines)
+	bufReader := bufio.NewReader(reader)
+
+	for int64(len(lines)) < nbLines {
+		line, err := bufReader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Very long line: keep its beginning and skip the rest
+			lines = append(lines, sanitizePreviewLine(line))
+			for err == bufio.ErrBufferFull {
+				_, err = bufReader.ReadSlice('\n')
+			}
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+
+		if len(line) > 0 {
+			lines = append(lines, sanitizePreviewLine(bytes.TrimSuffix(line,
+				[]byte{'\n'})))
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return lines, nil
+}
+
+// Reads the last lines of a file by reading blocks from its end
+func readTailLines(file *os.File, nbLines int64) ([]string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	end := info.Size()
+	var content []byte
+	// The last line may not be terminated by a newline
+	if end > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, end-1); err != nil {
+			return nil, err
+		}
+		if last[0] == '\n' {
+			end -= 1
+		}
+	}
+
+	offset := end
+	for offset > 0 && int64(bytes.Count(content, []byte{'\n'})) < nbLines {
+		blockSize := int64(previewBlockSize)
+		if offset < blockSize {
+			blockSize = offset
+		}
+		offset -= blockSize
+
+		block := make([]byte, blockSize)
+		if _, err := file.ReadAt(block, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		content = append(block, content...)
+	}
+
+	rawLines := bytes.Split(content[:end-offset], []byte{'\n'})
+	if int64(len(rawLines)) > nbLines {
+		rawLines = rawLines[int64(len(rawLines))-nbLines:]
+	}
+
+	lines := make([]string, len(rawLines))
+	for i, rawLine := range rawLines {
+		lines[i] = sanitizePreviewLine(rawLine)
+	}
+	return lines, nil
+}
+
+func readPlainFilePreview(filename string, maxLines int64) (FilePreview,
+	error) {
+	preview := FilePreview{Filename: filename}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return preview, fmt.Errorf("Cannot open '%s': %s", filename, err)
+	}
+	defer file.Close()
+
+	preview.TotalLines, err = countLines(file)
+	if err != nil {
+		return preview, fmt.Errorf("Cannot count the lines of '%s': %s",
+			filename, err)
+	}
+
+	nbHeadLines := maxLines / 2
+	if preview.TotalLines <= maxLines {
+		nbHeadLines = preview.TotalLines
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return preview, fmt.Errorf("Cannot read the first %d lines of '%s'",
+			nbHeadLines, filename)
+	}
+	preview.HeadLines, err = readHeadLines(file, nbHeadLines)
+	if err != nil {
+		return preview, fmt.Errorf("Cannot read the first %d lines of '%s'",
+			nbHeadLines, filename)
+	}
+
+	if preview.TotalLines > maxLines {
+		preview.Truncated = true
+		preview.TailLines, err = readTailLines(file, maxLines/2)
+		if err != nil {
+			return preview, fmt.Errorf("Cannot read the last %d lines of '%s'",
+				maxLines/2, filename)
+		}
+	}
+
+	return preview, nil
+}
+
+// Compressed files cannot be read from their end: they are read once,
+// only keeping their first and last lines in memory.
+func readGzipFilePreview(filename string, maxLines int64) (FilePreview,
+	error) {
+	preview := FilePreview{Filename: filename}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return preview, fmt.Errorf("Cannot open '%s': %s", filename, err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return preview, fmt.Errorf("Cannot decompress '%s'", filename)
+	}
+	defer reader.Close()
+
+	var head []string
+	tail := make([]string, 0, maxLines)
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadString('\n')
+		if len(line) > 0 {
+			preview.TotalLines += 1
+			sanitized := sanitizePreviewLine([]byte(strings.TrimSuffix(line,
+				"\n")))
+			if int64(len(head)) < maxLines/2 {
+				head = append(head, sanitized)
+			}
+			if int64(len(tail)) == maxLines {
+				tail = tail[1:]
+			}
+			tail = append(tail, sanitized)
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return preview, fmt.Errorf("Cannot decompress '%s'", filename)
+		}
+	}
+
+	if preview.TotalLines <= maxLines {
+		preview.HeadLines = tail
+	} else {
+		preview.Truncated = true
+		preview.HeadLines = head
+		preview.TailLines = tail[int64(len(tail))-maxLines/2:]
+	}
+
+	return preview, nil
+}
+
+// Reads a file and returns a preview of its content.
+// The whole file content is returned if it has at most maxLines lines.
+// Otherwise, only its first and last maxLines/2 lines are returned.
+// Compressed files (filename.gz) are read if filename does not exist.
+// Rotated files (filename.1, filename.2...) are listed in the preview.
+func ReadFilePreview(filename string, maxLines int64) (preview FilePreview,
+	err error) {
+	if _, statErr := os.Stat(filename); os.IsNotExist(statErr) {
+		if _, gzErr := os.Stat(filename + ".gz"); gzErr == nil {
+			filename = filename + ".gz"
+		}
+	}
+
+	if strings.HasSuffix(filename, ".gz") {
+		preview, err = readGzipFilePreview(filename, maxLines)
+	} else {
+		preview, err = readPlainFilePreview(filename, maxLines)
+	}
+
+	if err == nil {
+		preview.RotatedFiles = RotatedLogFiles(strings.TrimSuffix(filename,
+			".gz"))
+	}
+
+	return preview, err
+}
+
+func (preview FilePreview) String() string {
+	var builder strings.Builder
+	for _, line := range preview.HeadLines {
+		builder.WriteString(line + "\n")
+	}
+
+	if preview.Truncated {
+		fmt.Fprintf(&builder, "...\n...\n"+
+			"... (truncated... whole log in '%s')\n...\n...\n",
+			preview.Filename)
+		for _, line := range preview.TailLines {
+			builder.WriteString(line + "\n")
+		}
+	}
+
+	if len(preview.RotatedFiles) > 0 {
+		fmt.Fprintf(&builder, "...\n... (older content rotated to '%s')\n",
+			strings.Join(preview.RotatedFiles, "', '"))
+	}
+
+	return builder.String()
+}
+
+// Reads a file and returns a preview of its content, as text.
+// See ReadFilePreview for details.
+func PreviewFile(filename string, maxLines int64) (preview string, err error) {
+	filePreview, err := ReadFilePreview(filename, maxLines)
+	if err != nil {
+		return "", err
+	}
+	return filePreview.String(), nil
+}
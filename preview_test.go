@@ -0,0 +1,137 @@
+package batexpe
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes content in a file, compressed if the filename ends with .gz
+func writePreviewedFile(t *testing.T, filename, content string) {
+	if !strings.HasSuffix(filename, ".gz") {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFilePreview(t *testing.T) {
+	// Longer than the read buffers and than the preview blocks
+	longLine := strings.Repeat("x", 2*previewBlockSize)
+	cutLine := strings.Repeat("x", previewMaxLineLength) + " [...]"
+
+	tests := []struct {
+		name      string
+		content   string
+		maxLines  int64
+		head      []string
+		tail      []string
+		total     int64
+		truncated bool
+	}{
+		{"empty", "", 4, []string{}, nil, 0, false},
+		{"short", "1\n2\n", 4, []string{"1", "2"}, nil, 2, false},
+		{"no-trailing-newline", "1\n2", 4, []string{"1", "2"}, nil, 2,
+			false},
+		{"empty-lines", "\n\n\n", 4, []string{"", "", ""}, nil, 3, false},
+		{"exactly-max-lines", "1\n2\n3\n4\n", 4,
+			[]string{"1", "2", "3", "4"}, nil, 4, false},
+		{"max-lines-plus-one", "1\n2\n3\n4\n5\n", 4, []string{"1", "2"},
+			[]string{"4", "5"}, 5, true},
+		{"truncated-no-trailing-newline", "1\n2\n3\n4\n5", 4,
+			[]string{"1", "2"}, []string{"4", "5"}, 5, true},
+		{"odd-max-lines", "1\n2\n3\n4\n5\n6\n", 5, []string{"1", "2"},
+			[]string{"5", "6"}, 6, true},
+		{"binary", "ok\x00\x01\xff\xfe\r\n\tend\n", 4,
+			[]string{"ok���", "\tend"}, nil, 2, false},
+		{"long-line", "1\n" + longLine + "\n2\n", 4,
+			[]string{"1", cutLine, "2"}, nil, 3, false},
+		{"long-head-line", longLine + "\n1\n2\n3\n", 2, []string{cutLine},
+			[]string{"3"}, 4, true},
+		{"long-tail-line", "1\n2\n3\n" + longLine, 2, []string{"1"},
+			[]string{cutLine}, 4, true},
+	}
+
+	for _, test := range tests {
+		for _, suffix := range []string{"", ".gz"} {
+			t.Run(test.name+suffix, func(t *testing.T) {
+				filename := filepath.Join(t.TempDir(), "batsim.log")
+				writePreviewedFile(t, filename+suffix, test.content)
+
+				// Compressed files are found from the uncompressed filename
+				preview, err := ReadFilePreview(filename, test.maxLines)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if preview.Filename != filename+suffix {
+					t.Errorf("Unexpected filename: %s", preview.Filename)
+				}
+				if preview.TotalLines != test.total {
+					t.Errorf("Unexpected total lines: expected %d, got %d",
+						test.total, preview.TotalLines)
+				}
+				if preview.Truncated != test.truncated {
+					t.Errorf("Unexpected truncation: expected %t, got %t",
+						test.truncated, preview.Truncated)
+				}
+				if len(preview.HeadLines) != 0 || len(test.head) != 0 {
+					if !reflect.DeepEqual(preview.HeadLines, test.head) {
+						t.Errorf("Unexpected head lines: expected %q, got %q",
+							test.head, preview.HeadLines)
+					}
+				}
+				if len(preview.TailLines) != 0 || len(test.tail) != 0 {
+					if !reflect.DeepEqual(preview.TailLines, test.tail) {
+						t.Errorf("Unexpected tail lines: expected %q, got %q",
+							test.tail, preview.TailLines)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReadFilePreviewRotatedFiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "batsim.log")
+	writePreviewedFile(t, filename, "2\n")
+	writePreviewedFile(t, filename+".1.gz", "1\n")
+
+	preview, err := ReadFilePreview(filename, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.RotatedFiles) != 1 {
+		t.Errorf("Unexpected rotated files: %v", preview.RotatedFiles)
+	}
+	if !strings.Contains(preview.String(), "older content rotated") {
+		t.Errorf("Rotated files are not mentioned in the preview:\n%s",
+			preview.String())
+	}
+}
+
+func TestReadFilePreviewMissingFile(t *testing.T) {
+	_, err := ReadFilePreview(filepath.Join(t.TempDir(), "missing.log"), 4)
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -0,0 +1,194 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stores what is known about the progress of a running simulation.
+// LogLines counts the timestamped lines of Batsim's log, whatever they
+// report: it depends on Batsim's verbosity.
+type SimulationProgress struct {
+	SimulatedTime     float64
+	JobsSubmitted     int
+	JobsCompleted     int
+	LogLines          int
+	LogLinesPerSecond float64
+}
+
+var (
+	// SimGrid log lines are prefixed by [host:actor:(pid) simulated_time]
+	progressSimTimeRegex   = regexp.MustCompile(`^\[[^\]]*?(\d+(?:\.\d+)?)\]`)
+	progressSubmittedRegex = regexp.MustCompile(`(?i)\bjobs?\b.*\bsubmitted\b`)
+	progressCompletedRegex = regexp.MustCompile(`(?i)\bjobs?\b.*\b(completed|finished)\b`)
+)
+
+// Updates the progress from one Batsim log line
+func (progress *SimulationProgress) parseLine(line string) {
+	capture := progressSimTimeRegex.FindStringSubmatch(line)
+	if capture == nil {
+		return
+	}
+
+	progress.LogLines += 1
+	if simTime, err := strconv.ParseFloat(capture[1], 64); err == nil &&
+		simTime > progress.SimulatedTime {
+		progress.SimulatedTime = simTime
+	}
+
+	if progressSubmittedRegex.MatchString(line) {
+		progress.JobsSubmitted += 1
+	} else if progressCompletedRegex.MatchString(line) {
+		progress.JobsCompleted += 1
+	}
+}
+
+// Reads the lines appended to a file since the last call
+type fileTailer struct {
+	filename string
+	offset   int64
+	partial  string
+}
+
+func (tailer *fileTailer) newLines() []string {
+	file, err := os.Open(tailer.filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	// The file has been truncated or rotated
+	if info, err := file.Stat(); err == nil && info.Size() < tailer.offset {
+		tailer.offset = 0
+		tailer.partial = ""
+	}
+
+	if _, err := file.Seek(tailer.offset, io.SeekStart); err != nil {
+		return nil
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil
+	}
+	tailer.offset += int64(len(content))
+
+	// The last line may still be being written
+	lines := strings.Split(tailer.partial+string(content), "\n")
+	tailer.partial = lines[len(lines)-1]
+	return lines[:len(lines)-1]
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func logProgress(progress SimulationProgress, msg string) {
+	log.WithFields(log.Fields{
+		"simulated time":       progress.SimulatedTime,
+		"jobs submitted":       progress.JobsSubmitted,
+		"jobs completed":       progress.JobsCompleted,
+		"log lines":            progress.LogLines,
+		"log lines per second": progress.LogLinesPerSecond,
+	}).Info(msg)
+}
+
+func formatLiveStatus(progress SimulationProgress, elapsed time.Duration) string {
+	return fmt.Sprintf("[%s] simulated time: %.3f, jobs submitted: %d, "+
+		"jobs completed: %d, log lines/s: %.1f",
+		elapsed.Truncate(time.Second), progress.SimulatedTime,
+		progress.JobsSubmitted, progress.JobsCompleted,
+		progress.LogLinesPerSecond)
+}
+
+// Reports the progress of a simulation by tailing Batsim's log.
+// A log entry is emitted every opts.ProgressInterval seconds (if set),
+// and a live one-line status is written on stderr every second if
+// opts.LiveStatus is set and stderr is a terminal.
+func reportProgress(batsimLogFile string, opts ExecuteOptions,
+	stop chan bool, done chan bool) {
+	defer close(done)
+
+	tailer := fileTailer{filename: batsimLogFile}
+	var progress SimulationProgress
+
+	liveStatus := opts.LiveStatus && isTerminal(os.Stderr)
+	pollPeriod := time.Second
+	if opts.ProgressInterval > 0 && !liveStatus {
+		pollPeriod = time.Duration(opts.ProgressInterval * float64(time.Second))
+	}
+
+	startTime := time.Now()
+	lastPoll := startTime
+	lastReport := startTime
+	logLinesAtLastPoll := 0
+
+	poll := func() {
+		for _, line := range tailer.newLines() {
+			progress.parseLine(line)
+		}
+
+		now := time.Now()
+		if elapsed := now.Sub(lastPoll).Seconds(); elapsed > 0 {
+			progress.LogLinesPerSecond =
+				float64(progress.LogLines-logLinesAtLastPoll) / elapsed
+		}
+		logLinesAtLastPoll = progress.LogLines
+		lastPoll = now
+	}
+
+	for {
+		select {
+		case <-stop:
+			poll()
+			if liveStatus {
+				fmt.Fprint(os.Stderr, "\r\033[K")
+			}
+			if opts.ProgressInterval > 0 {
+				logProgress(progress, "Simulation progress (final)")
+			}
+			return
+		case <-time.After(pollPeriod):
+		}
+
+		poll()
+
+		if liveStatus {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s",
+				formatLiveStatus(progress, time.Since(startTime)))
+		}
+
+		if opts.ProgressInterval > 0 && time.Since(lastReport).Seconds() >=
+			opts.ProgressInterval {
+			logProgress(progress, "Simulation progress")
+			lastReport = time.Now()
+		}
+	}
+}
+
+// Starts the progress reporter if it is enabled.
+// The returned function stops the reporter and waits for its last report.
+func startProgressReporter(exp Experiment, opts ExecuteOptions) (stop func()) {
+	if opts.ProgressInterval <= 0 && !opts.LiveStatus {
+		return func() {}
+	}
+
+	stopChan := make(chan bool)
+	done := make(chan bool)
+	go reportProgress(exp.LogDir()+"/batsim.log", opts, stopChan, done)
+
+	return func() {
+		close(stopChan)
+		<-done
+	}
+}
@@ -0,0 +1,142 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func DescriptionFilename(outputDir string) string {
+	return outputDir + "/description.yaml"
+}
+
+// Writes the description of an experiment in its output directory,
+// so that it can be replayed later on
+func WriteDescription(exp Experiment) error {
+	yam, err := ToYaml(exp)
+	if err == nil {
+		err = ioutil.WriteFile(DescriptionFilename(exp.OutputDir), []byte(yam),
+			0644)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":              err,
+			"description file": DescriptionFilename(exp.OutputDir),
+		}).Error("Cannot write description file")
+		return fmt.Errorf("Cannot write description file")
+	}
+	return nil
+}
+
+// Replaces the paths that start with oldDir in a command
+func replaceOutputDir(command, oldDir, newDir string) string {
+	if oldDir == "" || oldDir == newDir {
+		return command
+	}
+
+	isSeparator := func(c byte) bool {
+		return strings.IndexByte(" \t\n'\"=", c) != -1
+	}
+
+	var builder strings.Builder
+	position := 0
+	for {
+		i := strings.Index(command[position:], oldDir)
+		if i == -1 {
+			builder.WriteString(command[position:])
+			return builder.String()
+		}
+
+		begin := position + i
+		end := begin + len(oldDir)
+		startsPath := begin == 0 || isSeparator(command[begin-1])
+		endsPath := end == len(command) || command[end] == '/' ||
+			isSeparator(command[end])
+
+		builder.WriteString(command[position:begin])
+		if startsPath && endsPath {
+			builder.WriteString(newDir)
+		} else {
+			builder.WriteString(oldDir)
+		}
+		position = end
+	}
+}
+
+// Reads the description written in the output directory of a previous run.
+// The experiment is set to run in outputDir, the occurrences of the previous
+// output directory being replaced in its commands.
+// The previous output directory itself is used if outputDir is empty.
+func ReplayExperiment(previousOutputDir, outputDir string) (Experiment,
+	error) {
+	filename := DescriptionFilename(previousOutputDir)
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":              err,
+			"description file": filename,
+		}).Error("Cannot open description file of previous run")
+		return Experiment{}, fmt.Errorf("Cannot open description file")
+	}
+
+	exp, err := FromYaml(string(byt))
+	if err != nil {
+		return exp, err
+	}
+
+	if outputDir == "" {
+		outputDir = previousOutputDir
+	}
+
+	// The previous run may have been moved since it has been executed
+	oldDir := exp.OutputDir
+	exp.Batcmd = replaceOutputDir(exp.Batcmd, oldDir, outputDir)
+	exp.Schedcmd = replaceOutputDir(exp.Schedcmd, oldDir, outputDir)
+	exp.OutputDir = outputDir
+
+	log.WithFields(log.Fields{
+		"previous output directory": oldDir,
+		"output directory":          outputDir,
+		"batsim command":            exp.Batcmd,
+		"scheduler command":         exp.Schedcmd,
+	}).Info("Replaying previous run")
+
+	return exp, nil
+}
+
+// Moves the logs, crash artifacts and result of a previous run into
+// outputDir/archive/<timestamp>. Returns the archive directory.
+func ArchiveRun(outputDir string) (string, error) {
+	archiveDir := outputDir + "/archive/" +
+		time.Now().Format("20060102-150405")
+	if err := CreateDirIfNeeded(archiveDir); err != nil {
+		return "", err
+	}
+
+	for _, name := range []string{"log", "crash", "result.json"} {
+		source := outputDir + "/" + name
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := os.Rename(source, archiveDir+"/"+name); err != nil {
+			log.WithFields(log.Fields{
+				"err":               err,
+				"file":              source,
+				"archive directory": archiveDir,
+			}).Error("Cannot archive previous run")
+			return archiveDir, fmt.Errorf("Cannot archive previous run")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"output directory":  outputDir,
+		"archive directory": archiveDir,
+	}).Info("Previous run archived")
+
+	return archiveDir, nil
+}
@@ -0,0 +1,208 @@
+package batexpe
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"time"
+)
+
+// Duration of one phase of a robin execution
+type PhaseDuration struct {
+	Phase    string    `json:"phase"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+}
+
+// Stores the wall-clock duration of each phase of a robin execution.
+// Phases are sequential: beginning a phase ends the current one.
+type Timeline struct {
+	Begin  time.Time
+	Phases []PhaseDuration
+
+	current      string
+	currentStart time.Time
+}
+
+func NewTimeline() *Timeline {
+	return &Timeline{Begin: time.Now()}
+}
+
+// Ends the current phase (if any) and begins a new one
+func (timeline *Timeline) StartPhase(phase string) {
+	timeline.EndPhase()
+	timeline.current = phase
+	timeline.currentStart = time.Now()
+}
+
+// Ends the current phase (if any)
+func (timeline *Timeline) EndPhase() {
+	if timeline.current == "" {
+		return
+	}
+
+	timeline.Phases = append(timeline.Phases, PhaseDuration{
+		Phase:    timeline.current,
+		Start:    timeline.currentStart,
+		Duration: time.Since(timeline.currentStart).Seconds(),
+	})
+	timeline.current = ""
+}
+
+// Returns whether a phase has been started
+func (timeline *Timeline) HasPhase(phase string) bool {
+	if timeline.current == phase {
+		return true
+	}
+	for _, phaseDuration := range timeline.Phases {
+		if phaseDuration.Phase == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the last started phase
+func (timeline *Timeline) LastPhase() string {
+	if timeline.current != "" {
+		return timeline.current
+	}
+	if len(timeline.Phases) == 0 {
+		return ""
+	}
+	return timeline.Phases[len(timeline.Phases)-1].Phase
+}
+
+// Duration since the beginning of the timeline, in seconds
+func (timeline *Timeline) Total() float64 {
+	return time.Since(timeline.Begin).Seconds()
+}
+
+func (timeline *Timeline) log() {
+	fields := log.Fields{
+		"total (seconds)": timeline.Total(),
+	}
+	for _, phaseDuration := range timeline.Phases {
+		fields[phaseDuration.Phase+" (seconds)"] = phaseDuration.Duration
+	}
+
+	log.WithFields(fields).Info("Phase timeline")
+}
+
+// Returns a human-readable name of an execution state
+func StateName(state int) string {
+	switch state {
+	case SUCCESS:
+		return "success"
+	case TIMEOUT:
+		return "timeout"
+	case FAILURE:
+		return "failure"
+	case ABORTED:
+		return "aborted"
+	case STALLED:
+		return "stalled"
+	default:
+		return fmt.Sprintf("unknown (%d)", state)
+	}
+}
+
+// Summary of how one process of a robin execution finished
+type ProcessResult struct {
+	State string               `json:"state"`
+	Err   string               `json:"err,omitempty"`
+	Error *ErrorClassification `json:"error,omitempty"`
+	Crash *CrashInfo           `json:"crash,omitempty"`
+}
+
+// Summary of one robin execution, exported as JSON in the output directory
+type RunResult struct {
+	State      string                   `json:"state"`
+	ReturnCode int                      `json:"return-code"`
+	Duration   float64                  `json:"duration"`
+	Timeline   []PhaseDuration          `json:"timeline"`
+	Processes  map[string]ProcessResult `json:"processes,omitempty"`
+	Attempts   []AttemptResult          `json:"attempts,omitempty"`
+}
+
+// Records what happens during one robin execution
+type executionRecord struct {
+	timeline  *Timeline
+	processes map[string]ProcessResult
+	// Set once the Batsim command has been parsed
+	batargs *BatsimArgs
+	// When the total timeout is reached (zero if there is no total timeout).
+	// It is shared by all the attempts of an execution.
+	deadline time.Time
+	// Set if the execution has been stopped by the total timeout
+	totalTimeout bool
+}
+
+func newExecutionRecord() *executionRecord {
+	return &executionRecord{
+		timeline:  NewTimeline(),
+		processes: make(map[string]ProcessResult),
+	}
+}
+
+func (record *executionRecord) processFinished(msg CmdFinishedMsg) {
+	processResult := ProcessResult{
+		State: StateName(msg.State),
+		Error: msg.Classification,
+		Crash: newCrashInfo(msg),
+	}
+	if msg.Err != nil {
+		processResult.Err = msg.Err.Error()
+	}
+	record.processes[msg.Name] = processResult
+}
+
+func newRunResult(returnCode int, record *executionRecord) RunResult {
+	timeline := record.timeline
+	result := RunResult{
+		ReturnCode: returnCode,
+		State:      StateName(returnCode),
+		Duration:   timeline.Total(),
+		Timeline:   timeline.Phases,
+		Processes:  record.processes,
+	}
+
+	// Robin failed before the simulation has been started
+	if !timeline.HasPhase("start") {
+		if timeline.LastPhase() == "wait-ready" {
+			result.State = "context-invalid"
+		} else {
+			result.State = "setup-failure"
+		}
+	}
+
+	if record.totalTimeout {
+		result.State = "total-timeout"
+	}
+
+	return result
+}
+
+func ResultFilename(outputDir string) string {
+	return outputDir + "/result.json"
+}
+
+func WriteResult(outputDir string, result RunResult) error {
+	byt, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ResultFilename(outputDir), byt, 0644)
+}
+
+// Reads the result written by robin in an output directory
+func ReadResult(outputDir string) (RunResult, error) {
+	var result RunResult
+	byt, err := ioutil.ReadFile(ResultFilename(outputDir))
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(byt, &result)
+	return result, err
+}
@@ -0,0 +1,95 @@
+package batexpe
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Summary of one attempt of a robin execution
+type AttemptResult struct {
+	Attempt    int     `json:"attempt"`
+	State      string  `json:"state"`
+	ReturnCode int     `json:"return-code"`
+	Duration   float64 `json:"duration"`
+	LogDir     string  `json:"log-dir"`
+}
+
+// Retried states if retries are enabled without retry-on
+var defaultRetryOn = []string{"context-invalid"}
+
+// Log directory of an attempt. The first attempt logs in OutputDir/log.
+func AttemptLogDir(outputDir string, attempt int) string {
+	if attempt <= 1 {
+		return outputDir + "/log"
+	}
+	return fmt.Sprintf("%s/log/attempt-%d", outputDir, attempt)
+}
+
+// Returns whether a failed attempt should be retried.
+// retry-on contains execution states (context-invalid, timeout...) and
+// error categories (zmq-address-in-use...).
+// Executions stopped by the total timeout are never retried.
+func shouldRetry(exp Experiment, result RunResult) bool {
+	if result.ReturnCode == SUCCESS || result.ReturnCode == ABORTED ||
+		result.State == "total-timeout" {
+		return false
+	}
+
+	retryOn := exp.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+
+	for _, reason := range retryOn {
+		if reason == result.State {
+			return true
+		}
+		for _, process := range result.Processes {
+			if process.Error != nil && process.Error.Category == reason {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Waits before the next attempt.
+// Returns SUCCESS once waited, ABORTED if robin has been asked to stop
+// meanwhile, or TIMEOUT if the deadline (if not zero) is reached first.
+func waitRetryBackoff(backoff float64, deadline time.Time) int {
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigterm)
+
+	var totalTimeout <-chan time.Time
+	if !deadline.IsZero() {
+		totalTimeout = time.After(time.Until(deadline))
+	}
+
+	select {
+	case <-totalTimeout:
+		return TIMEOUT
+	case <-time.After(time.Duration(backoff * float64(time.Second))):
+		// The next attempt would be stopped as soon as started
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return TIMEOUT
+		}
+		return SUCCESS
+	case <-sigterm:
+		log.Warn("SIGTERM received. Not retrying.")
+		return ABORTED
+	}
+}
+
+func logAttempts(attempts []AttemptResult) {
+	fields := log.Fields{}
+	for _, attempt := range attempts {
+		fields[fmt.Sprintf("attempt %d", attempt.Attempt)] = attempt.State
+	}
+
+	log.WithFields(fields).Info("Attempt history")
+}
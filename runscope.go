@@ -0,0 +1,114 @@
+package batexpe
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variable that tags the processes of one robin run.
+// Set in robin's environment, it is inherited by all robin's descendants
+// (Batsim, the scheduler and their subprocesses), even if they outlive robin.
+const RunMarkerVariable = "BATEXPE_RUN_MARKER"
+
+// What identifies the processes of one robin run, so that the cleanliness of
+// its context can be checked regardless of the other simulations running on
+// the machine
+type RunScope struct {
+	// Value of RunMarkerVariable in robin's environment
+	Marker string
+	// Batsim's socket endpoint (unchecked if empty or not TCP)
+	Socket string
+}
+
+// Returns a marker that is unique on the machine
+func NewRunMarker() string {
+	return fmt.Sprintf("run-%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+// Returns the environment variable (KEY=VALUE) that tags a run's processes
+func (scope RunScope) MarkerEnv() string {
+	return RunMarkerVariable + "=" + scope.Marker
+}
+
+// Returns the PIDs of the processes whose environment contains a run marker.
+// The environments are read from /proc: this only works on Linux.
+// The processes whose environment cannot be read (e.g. processes of other
+// users) are ignored.
+func ProcessesWithRunMarker(marker string) ([]int, error) {
+	environFiles, err := filepath.Glob("/proc/[0-9]*/environ")
+	if err == nil && len(environFiles) == 0 {
+		err = fmt.Errorf("No process environment found in /proc")
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Cannot list processes environments")
+		return nil, err
+	}
+
+	markerEnv := []byte(RunMarkerVariable + "=" + marker)
+	var pids []int
+	for _, environFile := range environFiles {
+		environ, err := ioutil.ReadFile(environFile)
+		if err != nil {
+			continue
+		}
+
+		for _, variable := range bytes.Split(environ, []byte{0}) {
+			if bytes.Equal(variable, markerEnv) {
+				pid, _ := strconv.Atoi(strings.Split(environFile, "/")[2])
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// Returns whether a run's context is busy: whether processes of the run are
+// running, or whether the run's TCP socket port is in use
+func IsRunScopeBusy(scope RunScope) (bool, error) {
+	if scope.Marker != "" {
+		pids, err := ProcessesWithRunMarker(scope.Marker)
+		if err != nil {
+			return false, err
+		}
+		if len(pids) > 0 {
+			log.WithFields(log.Fields{
+				"marker": scope.Marker,
+				"pids":   pids,
+			}).Debug("Processes of the run are running")
+			return true, nil
+		}
+	}
+
+	if strings.HasPrefix(scope.Socket, "tcp") {
+		port, err := PortFromBatSock(scope.Socket)
+		if err != nil {
+			return false, err
+		}
+
+		inUse, err := IsTcpPortInUse(port)
+		if err != nil {
+			return false, err
+		}
+		if inUse {
+			log.WithFields(log.Fields{
+				"socket endpoint": scope.Socket,
+			}).Debug("Socket of the run is in use")
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
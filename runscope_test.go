@@ -0,0 +1,69 @@
+package batexpe_test
+
+import (
+	"fmt"
+	"github.com/Lucas-Doctorate-Project/batexpe"
+	"github.com/Lucas-Doctorate-Project/batexpe/batexpetest"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestProcessesWithRunMarker(t *testing.T) {
+	scope := batexpe.RunScope{Marker: batexpe.NewRunMarker()}
+
+	pids, err := batexpe.ProcessesWithRunMarker(scope.Marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pids) != 0 {
+		t.Errorf("Unexpected processes with a new marker: %v", pids)
+	}
+
+	cmd := exec.Command("sleep", "10")
+	cmd.Env = append(os.Environ(), scope.MarkerEnv())
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	pids, err = batexpe.ProcessesWithRunMarker(scope.Marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pids) != 1 || pids[0] != cmd.Process.Pid {
+		t.Errorf("Unexpected processes with marker: expected [%d], got %v",
+			cmd.Process.Pid, pids)
+	}
+
+	busy, err := batexpe.IsRunScopeBusy(scope)
+	if err != nil || !busy {
+		t.Errorf("Unexpected clean run scope (err=%v)", err)
+	}
+}
+
+func TestIsRunScopeBusySocket(t *testing.T) {
+	scope := batexpe.RunScope{
+		Marker: batexpe.NewRunMarker(),
+		Socket: batexpetest.FreeSocket(t),
+	}
+	port, _ := batexpe.PortFromBatSock(scope.Socket)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	busy, err := batexpe.IsRunScopeBusy(scope)
+	if err != nil || !busy {
+		t.Errorf("Unexpected clean run scope while its socket is in use "+
+			"(err=%v)", err)
+	}
+
+	listener.Close()
+	busy, err = batexpe.IsRunScopeBusy(scope)
+	if err != nil || busy {
+		t.Errorf("Unexpected busy run scope (err=%v)", err)
+	}
+}
@@ -0,0 +1,80 @@
+package batexpe
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// Serializes the lines written by all the teed processes on robin's stdout
+var teeMutex sync.Mutex
+
+var teeColors = map[string]string{
+	"Batsim":    "\033[36m", // cyan
+	"Scheduler": "\033[35m", // magenta
+}
+
+const teeColorReset = "\033[0m"
+
+// Writes complete lines on an output, each line being prefixed
+type prefixedLineWriter struct {
+	out     io.Writer
+	prefix  string
+	suffix  string
+	partial []byte
+}
+
+func newPrefixedLineWriter(out io.Writer, name string,
+	colorize bool) *prefixedLineWriter {
+	writer := &prefixedLineWriter{out: out, prefix: "[" + name + "] "}
+	if color, ok := teeColors[name]; ok && colorize {
+		writer.prefix = color + writer.prefix
+		writer.suffix = teeColorReset
+	}
+	return writer
+}
+
+func (writer *prefixedLineWriter) Write(p []byte) (int, error) {
+	writer.partial = append(writer.partial, p...)
+
+	teeMutex.Lock()
+	defer teeMutex.Unlock()
+	for {
+		newline := bytes.IndexByte(writer.partial, '\n')
+		if newline == -1 {
+			break
+		}
+
+		line := writer.partial[:newline]
+		_, err := io.WriteString(writer.out,
+			writer.prefix+string(line)+writer.suffix+"\n")
+		writer.partial = writer.partial[newline+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Writes the last line, even if it is not terminated by a newline
+func (writer *prefixedLineWriter) Flush() {
+	if len(writer.partial) > 0 {
+		writer.Write([]byte("\n"))
+	}
+}
+
+// Returns the writer a process output should be redirected to.
+// If opts.Tee is set, the output is written both in the log file and on
+// robin's stdout (prefixed by the process name).
+// The returned function must be called once the process has finished.
+func processOutputWriter(logFile io.Writer, name string,
+	opts ExecuteOptions) (io.Writer, func()) {
+	if !opts.Tee {
+		return logFile, func() {}
+	}
+
+	teeWriter := newPrefixedLineWriter(os.Stdout, name, opts.TeeColor)
+	return io.MultiWriter(logFile, teeWriter), teeWriter.Flush
+}
@@ -78,6 +78,17 @@ teardown() {
     good_return_or_print
 }
 
+@test "batsched-schedcrash-mid-segfault-core-dumps" {
+    run robintest batsched_schedcrash_mid_segfault_core.yaml --test-timeout 30 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-sched-failure ${RT_CLEAN_CTX}
+    good_return_or_print
+
+    result=/tmp/robin/batsched_schedcrash_mid_segfault_core/result.json
+    grep -q '"crash"' ${result}
+    grep -q '"signal": "segmentation fault"' ${result}
+}
+
 @test "batsched-schedcrash-end-segfault-long-preview" {
     run robin batsched_schedcrash_end_segfault_long.yaml --preview-on-error
     [ "$status" -ne 0 ]
@@ -56,3 +56,33 @@ teardown() {
                   --expect-sched-killed ${RT_CLEAN_CTX}
     good_return_or_print
 }
+
+@test "batsim-sleepsched-stall" {
+    run robintest batsim_sleepsched_stall.yaml --test-timeout 20 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-sched-killed ${RT_CLEAN_CTX} \
+                  --expect-batsim-killed-by=stall-timeout \
+                  --expect-sched-killed-by=stall-timeout \
+                  --expect-duration-above=2 --expect-duration-below=15
+    good_return_or_print
+}
+
+@test "batsim-sleepsched-total-timeout" {
+    run robintest batsim_sleepsched_total_timeout.yaml --test-timeout 20 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-sched-killed ${RT_CLEAN_CTX} \
+                  --expect-batsim-killed-by=total-timeout \
+                  --expect-sched-killed-by=total-timeout \
+                  --expect-duration-above=2 --expect-duration-below=15
+    good_return_or_print
+}
+
+@test "batsim-badsched-success-timeout" {
+    run robintest batsim_badsched_wrongcmd.yaml --test-timeout 20 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-batsim-killed-by=success-timeout \
+                  --expect-sched-killed-by=none \
+                  --expect-duration-above=5 --expect-duration-below=15 \
+                  ${RT_CLEAN_CTX}
+    good_return_or_print
+}
@@ -9,7 +9,12 @@ def generate_bats_file(input_filename, output_filename):
     options_to_bypass = ['--help', '-h', '--version',
                          '--output-dir', '--batcmd', '--schedcmd',
                          '--simulation-timeout', '--ready-timeout',
-                         '--success-timeout', '--failure-timeout']
+                         '--success-timeout', '--failure-timeout',
+                         '--stall-timeout', '--total-timeout',
+                         '--progress', '--log-max-size', '--log-rotate',
+                         '--retries', '--retry-backoff', '--retry-on',
+                         '--debug-process', '--debug-wrapper',
+                         '--on-existing-output']
 
     with open(input_filename, "r") as in_file:
         content = [x.rstrip() for x in in_file.readlines()]
@@ -65,7 +70,8 @@ ROBINTEST_FILES = ["batsched_fast.bats",
                    "robintest_expectedfail.bats",
                    "robintest_expectedfail_timeout.bats",
                    "robintest_mock.bats",
-                   "badinputfiles.bats"
+                   "badinputfiles.bats",
+                   "robinfault.bats"
                   ]
 ROBIN_FILES = ["robin_cli.bats",
                "robin_mock.bats",
@@ -44,6 +44,18 @@ teardown() {
     good_return_or_print
 }
 
+@test "nosched-badinput-retry" {
+    run robintest batsim_nosched_badinput_retry.yaml --test-timeout 30 \
+                  --expect-robin-failure --expect-batsim-failure \
+                  --expect-no-sched ${RT_CLEAN_CTX}
+    good_return_or_print
+
+    [ -f /tmp/robin/batsim_nosched_badinput_retry/log/attempt-2/batsim.log ]
+    [ -f /tmp/robin/batsim_nosched_badinput_retry/log/attempt-3/batsim.log ]
+    [ ! -d /tmp/robin/batsim_nosched_badinput_retry/log/attempt-4 ]
+    grep -q '"attempt": 3' /tmp/robin/batsim_nosched_badinput_retry/result.json
+}
+
 @test "nosched-timeout" {
     run robintest batsim_nosched_timeout.yaml --test-timeout 5 \
                   --expect-robin-failure --expect-no-sched ${RT_CLEAN_CTX}
@@ -55,6 +67,11 @@ teardown() {
                   --expect-robin-success --expect-batsim-success \
                   --expect-no-sched ${RT_CLEAN_CTX}
     good_return_or_print
+
+    manifest=/tmp/robin/batsim_nosched_ok/artifacts.json
+    grep -q '"sha256"' ${manifest}
+    grep -q 'small_platform.xml' ${manifest}
+    grep -q 'out_jobs.csv' ${manifest}
 }
 
 @test "nosched-ok-alt" {
@@ -71,6 +88,23 @@ teardown() {
     good_return_or_print
 }
 
+@test "nosched-mismatching-dir-strict" {
+    run robintest batsim_nosched_mismatching_dir_strict.yaml --test-timeout 30 \
+                  --expect-robin-failure ${RT_CLEAN_CTX}
+    good_return_or_print
+}
+
+@test "nosched-ok-inject-prefix" {
+    run robintest batsim_nosched_ok_inject_prefix.yaml --test-timeout 30 \
+                  --expect-robin-success --expect-batsim-success \
+                  --expect-no-sched ${RT_CLEAN_CTX}
+    good_return_or_print
+
+    grep -q -- '-e /tmp/robin/batsim_nosched_ok_inject_prefix/out' \
+        /tmp/robin/batsim_nosched_ok_inject_prefix/cmd/batsim.bash
+    [ -f /tmp/robin/batsim_nosched_ok_inject_prefix/out_jobs.csv ]
+}
+
 @test "nosched-ok-long" {
     run robintest batsim_nosched_ok.yaml --test-timeout 30 \
                   --expect-robin-success --expect-batsim-success \
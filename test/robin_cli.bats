@@ -51,6 +51,237 @@
     [ "$status" -ne 0 ]
 }
 
+# Progress tests
+@test "cli-robin-ok-progress" {
+    run robin batsim_nosched_ok.yaml --progress=0.1
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ 'Simulation progress' ]]
+}
+
+@test "cli-robin-ok-progress-json" {
+    run robin batsim_nosched_ok.yaml --progress=0.1 --json-logs --live-status
+    [ "$status" -eq 0 ]
+}
+
+@test "cli-robin-bad-progress" {
+    run robin batsim_nosched_ok.yaml --progress=-1
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid progress period' ]]
+}
+
+# Tee tests
+@test "cli-robin-ok-tee" {
+    run robin batsim_nosched_ok.yaml --tee
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ '[Batsim] ' ]]
+    [ -s /tmp/robin/batsim_nosched_ok/log/batsim.log ]
+}
+
+@test "cli-robin-ok-tee-color" {
+    run robin batsched_ok.yaml --tee --tee-color
+    [ "$status" -eq 0 ]
+}
+
+@test "cli-robin-ok-tee-json" {
+    run robin batsim_nosched_ok.yaml --tee --json-logs
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ '"option":"--tee"' ]]
+    echo "${output}" | python3 -c \
+        'import json, sys; [json.loads(line) for line in sys.stdin]'
+}
+
+# Dry-run tests
+@test "cli-robin-ok-dry-run" {
+    rm -rf /tmp/robin/batsched_ok
+    run robin batsched_ok.yaml --dry-run
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ 'socket-endpoint: tcp://localhost:28000' ]]
+    [ -f /tmp/robin/batsched_ok/cmd/sched.bash ]
+    [ ! -f /tmp/robin/batsched_ok/log/batsim.log ]
+}
+
+@test "cli-robin-ok-dry-run-json" {
+    run robin batsim_nosched_ok.yaml --dry-run --json-logs
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ '"batexec-mode":true' ]]
+}
+
+# Debug tests
+@test "cli-robin-debug-wrapper-dry-run" {
+    run robin batsched_ok.yaml --dry-run \
+              --debug-process=Scheduler --debug-wrapper=valgrind
+    [ "$status" -eq 0 ]
+    grep -q '^valgrind --log-file=/tmp/robin/batsched_ok/log/scheduler.valgrind.log batsched' \
+        /tmp/robin/batsched_ok/cmd/sched.bash
+}
+
+@test "cli-robin-bad-debug-process" {
+    run robin batsched_ok.yaml --debug-process=Nobody
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'Invalid debugged process' ]]
+}
+
+@test "cli-robin-debug-process-batexec" {
+    run robin batsim_nosched_ok.yaml --debug-process=Batsim
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'Processes can only be started manually' ]]
+}
+
+# Replay tests
+@test "cli-robin-replay-new-output-dir" {
+    rm -rf /tmp/robin/batsim_nosched_ok_replay
+    run robin batsim_nosched_ok.yaml
+    [ "$status" -eq 0 ]
+    [ -f /tmp/robin/batsim_nosched_ok/description.yaml ]
+
+    run robin replay /tmp/robin/batsim_nosched_ok \
+              --output-dir=/tmp/robin/batsim_nosched_ok_replay
+    [ "$status" -eq 0 ]
+    grep -q -- '-e /tmp/robin/batsim_nosched_ok_replay/out' \
+        /tmp/robin/batsim_nosched_ok_replay/cmd/batsim.bash
+    [ -f /tmp/robin/batsim_nosched_ok_replay/log/batsim.log ]
+}
+
+@test "cli-robin-replay-in-place" {
+    rm -rf /tmp/robin/batsim_nosched_ok/archive
+    run robin batsim_nosched_ok.yaml
+    [ "$status" -eq 0 ]
+
+    run robin replay /tmp/robin/batsim_nosched_ok
+    [ "$status" -eq 0 ]
+    [ -f /tmp/robin/batsim_nosched_ok/archive/*/log/batsim.log ]
+    [ -f /tmp/robin/batsim_nosched_ok/log/batsim.log ]
+}
+
+@test "cli-robin-replay-no-description" {
+    run robin replay /this/directory/should/not/exist
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Cannot open description file of previous run' ]]
+}
+
+# Output directory policy tests
+@test "cli-robin-existing-output-fail" {
+    mkdir -p /tmp/robin/batsim_nosched_ok
+    touch /tmp/robin/batsim_nosched_ok/previous-file
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --on-existing-output=fail
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'Output directory already exists' ]]
+}
+
+@test "cli-robin-existing-output-overwrite" {
+    rm -rf /tmp/robin/batsim_nosched_ok
+    mkdir -p /tmp/robin/batsim_nosched_ok/log
+    touch /tmp/robin/batsim_nosched_ok/result.json
+    touch /tmp/robin/batsim_nosched_ok/log/previous.log
+    touch /tmp/robin/batsim_nosched_ok/previous-file
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --on-existing-output=overwrite
+    [ "$status" -eq 0 ]
+    [ ! -f /tmp/robin/batsim_nosched_ok/log/previous.log ]
+    [ -f /tmp/robin/batsim_nosched_ok/previous-file ]
+}
+
+@test "cli-robin-existing-output-overwrite-not-robin" {
+    rm -rf /tmp/robin/not_robin
+    mkdir -p /tmp/robin/not_robin
+    touch /tmp/robin/not_robin/previous-file
+    run robin --output-dir='/tmp/robin/not_robin' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/not_robin/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --on-existing-output=overwrite
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'Refusing to clean output directory' ]]
+    [ -f /tmp/robin/not_robin/previous-file ]
+}
+
+@test "cli-robin-existing-output-archive" {
+    rm -rf /tmp/robin/batsim_nosched_ok.*
+    mkdir -p /tmp/robin/batsim_nosched_ok
+    touch /tmp/robin/batsim_nosched_ok/previous-file
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --on-existing-output=archive
+    [ "$status" -eq 0 ]
+    [ ! -f /tmp/robin/batsim_nosched_ok/previous-file ]
+    [ -f /tmp/robin/batsim_nosched_ok.*/previous-file ]
+}
+
+@test "cli-robin-existing-output-bad-policy" {
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --on-existing-output=explode
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'Invalid on-existing-output policy' ]]
+}
+
+# Log size tests
+@test "cli-robin-ok-log-rotate-compress" {
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --log-max-size=1K --log-rotate=2 --log-compress
+    [ "$status" -eq 0 ]
+    [ -f /tmp/robin/batsim_nosched_ok/log/batsim.log.gz ]
+    [ ! -f /tmp/robin/batsim_nosched_ok/log/batsim.log.3.gz ]
+}
+
+@test "cli-robin-ok-log-truncate" {
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --log-max-size=1K
+    [ "$status" -eq 0 ]
+    [ $(stat -c %s /tmp/robin/batsim_nosched_ok/log/batsim.log) -le 1024 ]
+}
+
+@test "cli-robin-bad-log-max-size" {
+    run robin --output-dir='/tmp/robin/batsim_nosched_ok' \
+              --batcmd='batsim -p ${BATSIM_DIR}/platforms/small_platform.xml -w ${BATSIM_DIR}/workload_profiles/test_workload_profile.json -e /tmp/robin/batsim_nosched_ok/out --batexec' \
+              --schedcmd='' \
+              --simulation-timeout=30 \
+              --ready-timeout=5 \
+              --success-timeout=5 \
+              --failure-timeout=0 \
+              --log-max-size=huge
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid log max size' ]]
+}
+
 # generate subcommand test
 @test "cli-robin-generate-ok-nosched" {
     run robin generate /tmp/robin_generated.yaml \
@@ -0,0 +1,55 @@
+not_running() {
+    set +e
+    nb_running=$(ps -e -o command| cut -d' ' -f1| grep -E "\b$1$"| wc -l)
+    set -e
+
+    if [ "${nb_running}" -ne 0 ]; then
+        (>&2 echo "A '$1' process is still running")
+        return 1
+    fi
+}
+
+good_return_or_print() {
+    if [ "${status}" -ne 0 ]; then
+        (>&2 echo "${output}")
+        return 1
+    fi
+}
+
+# setup is called before each test
+setup() {
+    export RT_CLEAN_CTX="--expect-ctx-clean --expect-ctx-clean-at-begin --expect-ctx-clean-at-end"
+    killall batsim robin robin.cover batsched robinfault 2>/dev/null || true
+}
+
+# teardown is called after each test
+teardown() {
+    not_running batsim
+    not_running robin
+    not_running batsched
+    not_running robinfault
+}
+
+@test "robinfault-sched-segfault" {
+    run robintest batsched_fault_segfault.yaml --test-timeout 30 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-sched-failure ${RT_CLEAN_CTX} \
+                  --expect-log-match='Scheduler:Injecting fault'
+    good_return_or_print
+}
+
+@test "robinfault-sched-stop" {
+    run robintest batsched_fault_stop.yaml --test-timeout 30 \
+                  --expect-robin-failure \
+                  --expect-batsim-killed-by=stall-timeout \
+                  --expect-sched-killed-by=stall-timeout ${RT_CLEAN_CTX}
+    good_return_or_print
+}
+
+@test "robinfault-sched-holdport" {
+    run robintest batsched_fault_holdport.yaml --test-timeout 30 \
+                  --expect-robin-failure --expect-batsim-killed \
+                  --expect-sched-failure ${RT_CLEAN_CTX} \
+                  --expect-log-match='Scheduler:Port held'
+    good_return_or_print
+}
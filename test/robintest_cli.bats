@@ -52,3 +52,106 @@
     [ "$status" -ne 0 ]
     [[ "${lines[0]}" =~ 'Could not start Check subprocess' ]]
 }
+
+@test "cli-robintest-suite" {
+    run robintest suite robintest_suite.yaml --parallel=2
+    [ "$status" -eq 0 ]
+    [[ "${output}" =~ 'Test suite passed' ]]
+}
+
+@test "cli-robintest-suite-failing" {
+    run robintest suite robintest_suite_failing.yaml
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'robin success state (expected false, got true)' ]]
+}
+
+@test "cli-robintest-suite-invalid" {
+    run robintest suite robintest_suite_invalid.yaml
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid test case' ]]
+}
+
+@test "cli-robintest-suite-bad-parallel" {
+    run robintest suite robintest_suite.yaml --parallel=0
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid parallelism' ]]
+}
+
+@test "cli-robintest-report-junit" {
+    report=/tmp/robin/robintest_report.xml
+    rm -f ${report}
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --expect-robin-success --report-junit=${report}
+    [ "$status" -eq 0 ]
+    grep -q '<testcase name="robin success state"' ${report}
+    grep -q 'failures="0"' ${report}
+}
+
+@test "cli-robintest-suite-report-tap" {
+    run robintest suite robintest_suite_failing.yaml --report-tap
+    [ "$status" -ne 0 ]
+    [[ "${output}" =~ 'not ok 1 - nosched-ok-expected-failure: robin success state' ]]
+    [[ "${output}" =~ 'ok 2 - nosched-ok-expected-failure: robin kill state' ]]
+}
+
+@test "cli-robintest-bad-duration-expectation" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 --expect-duration-below=-1
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid duration expectation' ]]
+}
+
+@test "cli-robintest-bad-killing-timeout-expectation" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 --expect-sched-killed-by=ready-timeout
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid killing timeout expectation' ]]
+}
+
+@test "cli-robintest-content" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --expect-export-file=_jobs.csv \
+                  --expect-export-file=_schedule.csv \
+                  --expect-log-match='Batsim:\+ batsim' \
+                  --expect-log-no-match='Batsim:Traceback'
+    [ "$status" -eq 0 ]
+}
+
+@test "cli-robintest-content-missing-export-file" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --expect-export-file=_does_not_exist.csv
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Unexpected existence of /tmp/robin/batsim_nosched_ok/out_does_not_exist.csv' ]]
+}
+
+@test "cli-robintest-content-csv-value" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --expect-csv-value=_jobs.csv:job_id=not-a-job-id
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Unexpected CSV value /tmp/robin/batsim_nosched_ok/out_jobs.csv:job_id' ]]
+}
+
+@test "cli-robintest-content-bad-expectation" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --expect-log-match='Robin:anything'
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid content expectation' ]]
+}
+
+@test "cli-robintest-robin-binary-nonexistent" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 \
+                  --robin=/does/not/exist
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Could not start robin' ]]
+}
+
+@test "cli-robintest-ctx-scope-run" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 --ctx-scope=run \
+                  --expect-robin-success \
+                  --expect-ctx-clean-at-begin --expect-ctx-clean-at-end
+    [ "$status" -eq 0 ]
+}
+
+@test "cli-robintest-bad-ctx-scope" {
+    run robintest batsim_nosched_ok.yaml --test-timeout=10 --ctx-scope=machine
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Invalid context scope' ]]
+}
@@ -47,3 +47,15 @@ setup() {
     [[ "${lines[0]}" =~ "Unexpected context cleanliness during robin's execution" ]]
     killall batsim >/dev/null || true
 }
+
+@test "robintest-efail-batsim-killed-by-success-timeout" {
+    run robintest batsim_badsched_wrongcmd.yaml --test-timeout=10 --expect-batsim-killed-by=simulation-timeout
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Unexpected batsim killing timeout' ]]
+}
+
+@test "robintest-efail-duration-success-timeout" {
+    run robintest batsim_badsched_wrongcmd.yaml --test-timeout=10 --expect-duration-below=1
+    [ "$status" -ne 0 ]
+    [[ "${lines[0]}" =~ 'Unexpected robin duration (upper bound)' ]]
+}
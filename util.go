@@ -3,11 +3,11 @@ package batexpe
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 func CreateDirIfNeeded(dir string) error {
@@ -47,58 +47,21 @@ func PortFromBatSock(socket string) (port uint16, err error) {
 	return uint16(iport), nil
 }
 
-func PreviewFile(filename string, maxLines int64) (preview string, err error) {
-	// Retrieve the file length
-	wcCmd := exec.Command("wc")
-	wcCmd.Args = []string{wcCmd.Args[0], "-l", filename}
-
-	wcOut, err := wcCmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("Cannot call 'wc -l %s'", filename)
-	}
-
-	wcR := regexp.MustCompile(`(?m)^(\d+)\s+.*$`)
-	wcCap := wcR.FindStringSubmatch(string(wcOut))
-
-	if wcCap == nil {
-		return "", fmt.Errorf("Cannot retrieve number of lines in "+
-			"wc output '%s'", string(wcOut))
-	}
-
-	nbLines, _ := strconv.ParseInt(wcCap[1], 10, 32)
-
-	if nbLines <= maxLines {
-		// Retrieve the whole file content
-		content, err := ioutil.ReadFile(filename)
-		return string(content), err
-	} else {
-		// Only retrieve the first and last lines
-		// First lines
-		headCmd := exec.Command("head")
-		headCmd.Args = []string{headCmd.Args[0], "-n",
-			strconv.Itoa(int(maxLines / 2)), filename}
-
-		headOut, err := headCmd.Output()
-		if err != nil {
-			return "", fmt.Errorf("Cannot call 'head -n %s %s'",
-				string(maxLines/2), filename)
+// Splits a comma-separated list. Blank items are dropped.
+func SplitList(str string) []string {
+	var items []string
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-
-		// Last lines
-		tailCmd := exec.Command("tail")
-		tailCmd.Args = []string{tailCmd.Args[0], "-n",
-			strconv.Itoa(int(maxLines / 2)), filename}
-
-		tailOut, err := tailCmd.Output()
-		if err != nil {
-			return "", fmt.Errorf("Cannot call 'tail -n %s %s'",
-				string(maxLines/2), filename)
-		}
-
-		return fmt.Sprintf("%s...\n...\n"+
-			"... (truncated... whole log in '%s')\n...\n...\n%s",
-			string(headOut), filename, string(tailOut)), nil
 	}
+	return items
+}
+
+// Whether logs are currently written in JSON
+func isJSONLogging() bool {
+	_, isJSON := log.StandardLogger().Formatter.(*log.JSONFormatter)
+	return isJSON
 }
 
 func IsBatsimOrBatschedRunning() (bool, error) {
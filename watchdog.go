@@ -0,0 +1,168 @@
+package batexpe
+
+import (
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files whose growth shows that a simulation is still making progress
+func watchedFiles(exp Experiment, batargs BatsimArgs) []string {
+	files, _ := filepath.Glob(batargs.ExportPrefix + "*")
+	logs, _ := filepath.Glob(exp.LogDir() + "/*")
+	return append(files, logs...)
+}
+
+func filesSizes(filenames []string) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, filename := range filenames {
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			sizes[filename] = info.Size()
+		}
+	}
+	return sizes
+}
+
+func filesGrew(before, after map[string]int64) bool {
+	for filename, size := range after {
+		if oldSize, ok := before[filename]; !ok || size != oldSize {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the CPU time (in clock ticks) consumed by each process group.
+// Process groups without any running process are absent from the result.
+// An error is returned if /proc cannot be read (non-Linux systems).
+func processGroupsCPUTicks(pgids []int) (map[int]uint64, error) {
+	procEntries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int]bool)
+	for _, pgid := range pgids {
+		wanted[pgid] = true
+	}
+
+	ticks := make(map[int]uint64)
+	for _, entry := range procEntries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue // The process may have finished in the meantime
+		}
+
+		// The command name may contain spaces, fields are after its ')'
+		stat := string(content)
+		fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+		if len(fields) < 13 {
+			continue
+		}
+
+		pgid, _ := strconv.Atoi(fields[2])
+		if !wanted[pgid] {
+			continue
+		}
+
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		ticks[pgid] += utime + stime
+	}
+
+	return ticks, nil
+}
+
+// Whether no process group consumed CPU between two samples
+func cpuIdle(before, after map[int]uint64) bool {
+	for pgid, afterTicks := range after {
+		if beforeTicks, ok := before[pgid]; !ok || afterTicks != beforeTicks {
+			return false
+		}
+	}
+	return true
+}
+
+// Watches the activity of running simulation processes.
+// A stall reason is written on onstall if the simulation output files stop
+// growing for exp.StallTimeout seconds, or if all the processes do not
+// consume any CPU for exp.StallTimeout seconds.
+// Processes are identified by their process group (their pid as Setpgid is
+// set on robin's subprocesses). victims names them in the stall log.
+func watchStall(exp Experiment, batargs BatsimArgs, pgids []int,
+	victims string, stop chan bool, onstall chan string) {
+	stallDuration := time.Duration(exp.StallTimeout * float64(time.Second))
+	pollPeriod := stallDuration / 10
+	if pollPeriod > time.Second {
+		pollPeriod = time.Second
+	} else if pollPeriod < 10*time.Millisecond {
+		pollPeriod = 10 * time.Millisecond
+	}
+
+	lastOutputActivity := time.Now()
+	lastCPUActivity := time.Now()
+
+	sizes := filesSizes(watchedFiles(exp, batargs))
+	ticks, cpuErr := processGroupsCPUTicks(pgids)
+	if cpuErr != nil {
+		log.WithFields(log.Fields{
+			"err": cpuErr,
+		}).Warn("Cannot monitor CPU usage, stall detection only relies on output files")
+	}
+
+	log.WithFields(log.Fields{
+		"stall timeout (seconds)": exp.StallTimeout,
+		"watched process groups":  pgids,
+	}).Debug("Stall watchdog started")
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(pollPeriod):
+		}
+
+		newSizes := filesSizes(watchedFiles(exp, batargs))
+		if filesGrew(sizes, newSizes) {
+			lastOutputActivity = time.Now()
+		}
+		sizes = newSizes
+
+		if cpuErr == nil {
+			newTicks, err := processGroupsCPUTicks(pgids)
+			if err == nil {
+				if !cpuIdle(ticks, newTicks) {
+					lastCPUActivity = time.Now()
+				}
+				ticks = newTicks
+			}
+		}
+
+		reason := ""
+		if time.Since(lastOutputActivity) >= stallDuration {
+			reason = "output files stopped growing"
+		} else if cpuErr == nil && time.Since(lastCPUActivity) >= stallDuration {
+			reason = "processes stopped consuming CPU"
+		}
+
+		if reason != "" {
+			log.WithFields(log.Fields{
+				"event":                   EventStall,
+				"stall timeout (seconds)": exp.StallTimeout,
+				"reason":                  reason,
+				"watched process groups":  pgids,
+				"victim names":            victims,
+			}).Error("Simulation stalled")
+			onstall <- reason
+			return
+		}
+	}
+}